@@ -6,9 +6,42 @@ import (
 	"encoding/xml"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// MaxCellLength is the maximum number of characters in a cell. Excel counts
+// UTF-16 code units, so characters outside the BMP, such as most emoji, count
+// as two.
+const MaxCellLength = 32_767
+
+// LongStringPolicy decides what happens with strings longer than
+// MaxCellLength. See StreamXLSX.LongStrings.
+type LongStringPolicy int
+
+const (
+	// LongStringError makes WriteRow() return a *CellLengthError.
+	LongStringError LongStringPolicy = iota
+	// LongStringTruncate cuts the string, and ends it with TruncatedMarker.
+	LongStringTruncate
+	// LongStringSplit continues the string in the next cell(s). Cells after
+	// it in the row shift to the right.
+	LongStringSplit
+)
+
+// TruncatedMarker ends strings cut by LongStringTruncate.
+const TruncatedMarker = "..."
+
+// CellLengthError is returned for strings longer than MaxCellLength.
+type CellLengthError struct {
+	Ref    string
+	Length int
+}
+
+func (e *CellLengthError) Error() string {
+	return fmt.Sprintf("cell %s: string of %d characters is longer than %d", e.Ref, e.Length, MaxCellLength)
+}
+
 // These can be passed to `WriteRow()` if you want total control. WriteRow()
 // will fill int the `.Ref` value.
 // Exactly one of Value or InlineString should be set.
@@ -19,6 +52,8 @@ type Cell struct {
 	Value        string  `xml:"v,omitempty"`
 	InlineString *string `xml:"is>t,omitempty"`
 	hyperlink    *hyperlink
+	comment      *Comment
+	formula      string
 }
 
 func writeCell(w *bufio.Writer, c Cell) error {
@@ -33,11 +68,16 @@ func writeCell(w *bufio.Writer, c Cell) error {
 		w.WriteString(strconv.Itoa(*c.Style))
 	}
 	w.WriteString(`">`)
+	if c.formula != "" {
+		w.WriteString(`<f>`)
+		xml.EscapeText(w, []byte(c.formula))
+		w.WriteString(`</f>`)
+	}
 	if c.InlineString != nil {
 		w.WriteString(`<is><t>`)
 		xml.EscapeText(w, []byte(*c.InlineString))
 		w.WriteString(`</t></is>`)
-	} else {
+	} else if c.Value != "" || (c.formula == "" && c.Type != "") {
 		w.WriteString(`<v>`)
 		xml.EscapeText(w, []byte(c.Value))
 		w.WriteString(`</v>`)
@@ -90,18 +130,107 @@ func asCell(v interface{}) (Cell, error) {
 			Value: v,
 		}, nil
 	case Hyperlink:
-		cell, err := asCell(vt.Title)
+		title := vt.Title
+		if title == nil {
+			// "#" marks internal links, see LocationLink()
+			title = strings.TrimPrefix(vt.URL, "#")
+		}
+		cell, err := asCell(title)
+		display, _ := title.(string)
 		cell.hyperlink = &hyperlink{
 			url:     vt.URL,
-			Display: vt.Title,
+			Display: display,
 			Tooltip: vt.Tooltip,
 		}
 		return cell, err
+	case Comment:
+		for _, r := range vt.Rich {
+			if r.Color != "" {
+				if err := validateColor(r.Color); err != nil {
+					return Cell{}, err
+				}
+			}
+		}
+		// a comment on an empty cell
+		if vt.Value == nil {
+			return Cell{comment: &vt}, nil
+		}
+		cell, err := asCell(vt.Value)
+		cell.comment = &vt
+		return cell, err
 	default:
 		return Cell{}, fmt.Errorf("unsupported cell type: %T", vt)
 	}
 }
 
+// limitLength applies the policy to cells with a too long inline string. It
+// returns the cell(s) to write, starting at the given ref.
+func limitLength(policy LongStringPolicy, ref string, c Cell) ([]Cell, error) {
+	if c.InlineString == nil {
+		return []Cell{c}, nil
+	}
+	n := utf16Len(*c.InlineString)
+	if n <= MaxCellLength {
+		return []Cell{c}, nil
+	}
+
+	switch policy {
+	case LongStringTruncate:
+		v := truncateUTF16(*c.InlineString, MaxCellLength-utf16Len(TruncatedMarker)) + TruncatedMarker
+		c.InlineString = &v
+		return []Cell{c}, nil
+	case LongStringSplit:
+		var (
+			cells []Cell
+			rest  = *c.InlineString
+		)
+		for rest != "" {
+			part := truncateUTF16(rest, MaxCellLength)
+			rest = rest[len(part):]
+			p := c
+			p.InlineString = &part
+			if len(cells) > 0 {
+				p.hyperlink = nil
+				p.comment = nil
+			}
+			cells = append(cells, p)
+		}
+		return cells, nil
+	default:
+		return nil, &CellLengthError{Ref: ref, Length: n}
+	}
+}
+
+// utf16Len is the length of a string in UTF-16 code units.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16RuneLen(r)
+	}
+	return n
+}
+
+// utf16RuneLen is 2 for runes which need a surrogate pair.
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// truncateUTF16 cuts a string to at most n UTF-16 code units, without
+// splitting surrogate pairs.
+func truncateUTF16(s string, n int) string {
+	for i, r := range s {
+		l := utf16RuneLen(r)
+		if l > n {
+			return s[:i]
+		}
+		n -= l
+	}
+	return s
+}
+
 func applyStyle(id int, v interface{}) (Cell, error) {
 	c, err := asCell(v)
 	c.Style = &id
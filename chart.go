@@ -0,0 +1,266 @@
+package streamxlsx
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Types of charts.
+const (
+	ChartBar     = "bar" // horizontal bars
+	ChartColumn  = "column"
+	ChartLine    = "line"
+	ChartPie     = "pie"
+	ChartScatter = "scatter"
+	ChartArea    = "area"
+)
+
+// default chart size, in pixels
+const (
+	defaultChartWidth  = 480
+	defaultChartHeight = 288
+)
+
+// Chart is a chart in a sheet, with data from ranges in the workbook. See
+// AddChart().
+type Chart struct {
+	Type   string // one of the Chart* constants
+	Title  string
+	Series []ChartSeries
+	// Axis titles. Not used for pie charts.
+	XAxisTitle, YAxisTitle string
+	// Legend position: "r" (the default), "l", "t", "b", or "none".
+	Legend string
+	// Ref is the cell of the top left corner, such as "E2".
+	Ref string
+	// Width and Height are in pixels. Defaults to 480x288.
+	Width, Height int
+	// ToRef, if set, is the cell of the bottom right corner, and the chart is
+	// stretched between Ref and ToRef. Width and Height are ignored.
+	ToRef string
+}
+
+// ChartSeries is a data series in a chart. Ranges are absolute refs, such as
+// "'Data'!$B$2:$B$10" (see SheetRef()).
+type ChartSeries struct {
+	Name string
+	// Categories are the labels, or the X values for a scatter chart.
+	Categories string
+	Values     string
+	Color      string // "RRGGBB". Optional.
+}
+
+// AddChart places a chart in the current sheet. The chart is written when the
+// sheet is closed, so this can be called any time before WriteSheet(). The
+// ranges can be on other sheets, including ones which are not written yet.
+func (s *StreamXLSX) AddChart(c Chart) error {
+	if s.error != nil {
+		return s.error
+	}
+	sh, err := s.sheet()
+	if err != nil {
+		s.error = err
+		return err
+	}
+	a, err := chartAnchor(c)
+	if err != nil {
+		s.error = err
+		return err
+	}
+	sh.addAnchor(a)
+	return nil
+}
+
+func chartAnchor(c Chart) (anchor, error) {
+	switch c.Type {
+	case ChartBar, ChartColumn, ChartLine, ChartPie, ChartScatter, ChartArea:
+	default:
+		return anchor{}, fmt.Errorf("invalid chart type: %q", c.Type)
+	}
+	if len(c.Series) == 0 {
+		return anchor{}, errors.New("chart without series")
+	}
+	for _, ser := range c.Series {
+		if ser.Values == "" {
+			return anchor{}, errors.New("chart series without values")
+		}
+	}
+	switch c.Legend {
+	case "", "r", "l", "t", "b", "none":
+	default:
+		return anchor{}, fmt.Errorf("invalid legend position: %q", c.Legend)
+	}
+
+	a := anchor{chart: &c}
+	var err error
+	if a.from[0], a.from[1], err = parseRef(c.Ref); err != nil {
+		return anchor{}, err
+	}
+	if c.ToRef != "" {
+		a.twoCell = true
+		if a.to[0], a.to[1], err = parseRef(c.ToRef); err != nil {
+			return anchor{}, err
+		}
+		if a.to[0] < a.from[0] || a.to[1] < a.from[1] {
+			return anchor{}, fmt.Errorf("chart: %s is not below/right of %s", c.ToRef, c.Ref)
+		}
+		return a, nil
+	}
+	w, h := c.Width, c.Height
+	if w == 0 {
+		w = defaultChartWidth
+	}
+	if h == 0 {
+		h = defaultChartHeight
+	}
+	a.cx, a.cy = w*emuPerPixel, h*emuPerPixel
+	return a, nil
+}
+
+func encodeChartFrame(w *bufio.Writer, id int, a anchor) {
+	fmt.Fprintf(w, `<xdr:graphicFrame macro=""><xdr:nvGraphicFramePr><xdr:cNvPr id="%d"`, id)
+	writeAttr(w, "name", a.name)
+	w.WriteString(`/><xdr:cNvGraphicFramePr/></xdr:nvGraphicFramePr>`)
+	w.WriteString(`<xdr:xfrm><a:off x="0" y="0"/><a:ext cx="0" cy="0"/></xdr:xfrm>`)
+	fmt.Fprintf(w, `<a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/chart"><c:chart xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart" r:id="%s"/></a:graphicData></a:graphic>`, a.relID)
+	w.WriteString(`</xdr:graphicFrame>`)
+}
+
+func writeChart(fh io.Writer, c Chart) error {
+	w := bufio.NewWriter(fh)
+	w.WriteString(xml.Header)
+	w.WriteString(`<c:chartSpace xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><c:chart>`)
+	if c.Title != "" {
+		encodeChartTitle(w, c.Title)
+		w.WriteString(`<c:autoTitleDeleted val="0"/>`)
+	} else {
+		w.WriteString(`<c:autoTitleDeleted val="1"/>`)
+	}
+
+	w.WriteString(`<c:plotArea><c:layout/>`)
+	switch c.Type {
+	case ChartBar, ChartColumn:
+		dir := "col"
+		if c.Type == ChartBar {
+			dir = "bar"
+		}
+		fmt.Fprintf(w, `<c:barChart><c:barDir val="%s"/><c:grouping val="clustered"/><c:varyColors val="0"/>`, dir)
+		encodeChartSeries(w, c)
+		w.WriteString(`<c:axId val="1"/><c:axId val="2"/></c:barChart>`)
+	case ChartLine:
+		w.WriteString(`<c:lineChart><c:grouping val="standard"/><c:varyColors val="0"/>`)
+		encodeChartSeries(w, c)
+		w.WriteString(`<c:marker val="1"/><c:axId val="1"/><c:axId val="2"/></c:lineChart>`)
+	case ChartArea:
+		w.WriteString(`<c:areaChart><c:grouping val="standard"/><c:varyColors val="0"/>`)
+		encodeChartSeries(w, c)
+		w.WriteString(`<c:axId val="1"/><c:axId val="2"/></c:areaChart>`)
+	case ChartPie:
+		w.WriteString(`<c:pieChart><c:varyColors val="1"/>`)
+		encodeChartSeries(w, c)
+		w.WriteString(`<c:firstSliceAng val="0"/></c:pieChart>`)
+	case ChartScatter:
+		w.WriteString(`<c:scatterChart><c:scatterStyle val="lineMarker"/><c:varyColors val="0"/>`)
+		encodeChartSeries(w, c)
+		w.WriteString(`<c:axId val="1"/><c:axId val="2"/></c:scatterChart>`)
+	}
+
+	switch c.Type {
+	case ChartPie:
+	case ChartScatter:
+		encodeChartAxis(w, "valAx", 1, 2, "b", c.XAxisTitle, false)
+		encodeChartAxis(w, "valAx", 2, 1, "l", c.YAxisTitle, true)
+	case ChartBar:
+		encodeChartAxis(w, "catAx", 1, 2, "l", c.XAxisTitle, false)
+		encodeChartAxis(w, "valAx", 2, 1, "b", c.YAxisTitle, true)
+	default:
+		encodeChartAxis(w, "catAx", 1, 2, "b", c.XAxisTitle, false)
+		encodeChartAxis(w, "valAx", 2, 1, "l", c.YAxisTitle, true)
+	}
+	w.WriteString(`</c:plotArea>`)
+
+	if c.Legend != "none" {
+		pos := c.Legend
+		if pos == "" {
+			pos = "r"
+		}
+		fmt.Fprintf(w, `<c:legend><c:legendPos val="%s"/><c:overlay val="0"/></c:legend>`, pos)
+	}
+	w.WriteString(`<c:plotVisOnly val="1"/></c:chart></c:chartSpace>`)
+	return w.Flush()
+}
+
+func encodeChartSeries(w *bufio.Writer, c Chart) {
+	for i, ser := range c.Series {
+		fmt.Fprintf(w, `<c:ser><c:idx val="%d"/><c:order val="%d"/>`, i, i)
+		if ser.Name != "" {
+			w.WriteString(`<c:tx>`)
+			writeElem(w, "c:v", ser.Name)
+			w.WriteString(`</c:tx>`)
+		}
+		if ser.Color != "" {
+			w.WriteString(`<c:spPr>`)
+			switch c.Type {
+			case ChartLine, ChartScatter:
+				w.WriteString(`<a:ln><a:solidFill>`)
+				encodeSrgbClr(w, ser.Color)
+				w.WriteString(`</a:solidFill></a:ln>`)
+			default:
+				w.WriteString(`<a:solidFill>`)
+				encodeSrgbClr(w, ser.Color)
+				w.WriteString(`</a:solidFill>`)
+			}
+			w.WriteString(`</c:spPr>`)
+		}
+		if c.Type == ChartScatter {
+			if ser.Categories != "" {
+				w.WriteString(`<c:xVal><c:numRef>`)
+				writeElem(w, "c:f", ser.Categories)
+				w.WriteString(`</c:numRef></c:xVal>`)
+			}
+			w.WriteString(`<c:yVal><c:numRef>`)
+			writeElem(w, "c:f", ser.Values)
+			w.WriteString(`</c:numRef></c:yVal><c:smooth val="0"/>`)
+		} else {
+			if ser.Categories != "" {
+				w.WriteString(`<c:cat><c:strRef>`)
+				writeElem(w, "c:f", ser.Categories)
+				w.WriteString(`</c:strRef></c:cat>`)
+			}
+			w.WriteString(`<c:val><c:numRef>`)
+			writeElem(w, "c:f", ser.Values)
+			w.WriteString(`</c:numRef></c:val>`)
+			if c.Type == ChartLine {
+				w.WriteString(`<c:smooth val="0"/>`)
+			}
+		}
+		w.WriteString(`</c:ser>`)
+	}
+}
+
+func encodeChartAxis(w *bufio.Writer, tag string, id, crossID int, pos, title string, gridlines bool) {
+	fmt.Fprintf(w, `<c:%s><c:axId val="%d"/><c:scaling><c:orientation val="minMax"/></c:scaling><c:delete val="0"/><c:axPos val="%s"/>`, tag, id, pos)
+	if gridlines {
+		w.WriteString(`<c:majorGridlines/>`)
+	}
+	if title != "" {
+		encodeChartTitle(w, title)
+	}
+	fmt.Fprintf(w, `<c:crossAx val="%d"/></c:%s>`, crossID, tag)
+}
+
+func encodeChartTitle(w *bufio.Writer, title string) {
+	w.WriteString(`<c:title><c:tx><c:rich><a:bodyPr/><a:p><a:r>`)
+	writeElem(w, "a:t", title)
+	w.WriteString(`</a:r></a:p></c:rich></c:tx><c:overlay val="0"/></c:title>`)
+}
+
+func encodeSrgbClr(w *bufio.Writer, rgb string) {
+	w.WriteString(`<a:srgbClr`)
+	writeAttr(w, "val", strings.ToUpper(strings.TrimPrefix(rgb, "#")))
+	w.WriteString(`/>`)
+}
@@ -0,0 +1,159 @@
+package streamxlsx
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Comment adds a note to a cell. You can use these as a value in WriteRow(),
+// and Value is the cell value, which can be anything WriteRow() accepts, or
+// nil for an empty cell.
+// (implementation detail: comments are buffered until the sheet is closed)
+type Comment struct {
+	Value  interface{}
+	Author string
+	Text   string
+	// Rich, if set, is used instead of Text.
+	Rich []RichText
+}
+
+// RichText is a run of formatted text.
+type RichText struct {
+	Text         string
+	Bold, Italic bool
+	Color        string // as "RRGGBB". Optional.
+}
+
+type comment struct {
+	ref      string
+	row, col int
+	author   string
+	text     []RichText
+}
+
+func (c Comment) runs() []RichText {
+	if len(c.Rich) > 0 {
+		return c.Rich
+	}
+	return []RichText{{Text: c.Text}}
+}
+
+// addComment buffers a comment, and adds the relationships the first time.
+func (sh *sheetEncoder) addComment(c comment) {
+	if len(sh.comments) == 0 {
+		sh.relations = append(sh.relations,
+			relationship{
+				ID:     "commentsId1",
+				Target: fmt.Sprintf("/xl/comments%d.xml", sh.id),
+				Type:   "http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments",
+			},
+			relationship{
+				ID:     "vmlId1",
+				Target: fmt.Sprintf("/xl/drawings/vmlDrawing%d.vml", sh.id),
+				Type:   "http://schemas.openxmlformats.org/officeDocument/2006/relationships/vmlDrawing",
+			},
+		)
+	}
+	sh.comments = append(sh.comments, c)
+}
+
+// encodeLegacyDrawing writes the ref to the VML needed to show comments.
+func encodeLegacyDrawing(w *bufio.Writer, comments []comment) {
+	if len(comments) == 0 {
+		return
+	}
+	w.WriteString(`<legacyDrawing r:id="vmlId1"/>`)
+}
+
+func writeComments(fh io.Writer, comments []comment) error {
+	w := bufio.NewWriter(fh)
+	w.WriteString(xml.Header)
+	w.WriteString(`<comments xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+
+	var (
+		authors   []string
+		authorIDs = map[string]int{}
+	)
+	for _, c := range comments {
+		if _, ok := authorIDs[c.author]; !ok {
+			authorIDs[c.author] = len(authors)
+			authors = append(authors, c.author)
+		}
+	}
+	w.WriteString(`<authors>`)
+	for _, a := range authors {
+		w.WriteString(`<author>`)
+		xml.EscapeText(w, []byte(a))
+		w.WriteString(`</author>`)
+	}
+	w.WriteString(`</authors>`)
+
+	w.WriteString(`<commentList>`)
+	for _, c := range comments {
+		fmt.Fprintf(w, `<comment ref="%s" authorId="%d"><text>`, c.ref, authorIDs[c.author])
+		for _, r := range c.text {
+			w.WriteString(`<r>`)
+			if r.Bold || r.Italic || r.Color != "" {
+				w.WriteString(`<rPr>`)
+				if r.Bold {
+					w.WriteString(`<b/>`)
+				}
+				if r.Italic {
+					w.WriteString(`<i/>`)
+				}
+				if r.Color != "" {
+					w.WriteString(`<color rgb="`)
+					xml.EscapeText(w, []byte(argb(r.Color)))
+					w.WriteString(`"/>`)
+				}
+				w.WriteString(`</rPr>`)
+			}
+			w.WriteString(`<t xml:space="preserve">`)
+			xml.EscapeText(w, []byte(r.Text))
+			w.WriteString(`</t></r>`)
+		}
+		w.WriteString(`</text></comment>`)
+	}
+	w.WriteString(`</commentList></comments>`)
+	return w.Flush()
+}
+
+// vmlBlockSize is the number of shape IDs in a block of an <o:idmap>.
+const vmlBlockSize = 1024
+
+// writeVMLDrawing writes the legacy drawing Excel needs to show comments.
+// Shape IDs are claimed in blocks, which can't be shared between sheets. The
+// shapes are numbered from the first block on, and it returns the next free
+// block.
+func writeVMLDrawing(fh io.Writer, firstBlock int, comments []comment) (int, error) {
+	lastBlock := (firstBlock*vmlBlockSize + len(comments)) / vmlBlockSize
+	blocks := make([]string, 0, lastBlock-firstBlock+1)
+	for b := firstBlock; b <= lastBlock; b++ {
+		blocks = append(blocks, strconv.Itoa(b))
+	}
+
+	w := bufio.NewWriter(fh)
+	fmt.Fprintf(w, `<xml xmlns:v="urn:schemas-microsoft-com:vml" xmlns:o="urn:schemas-microsoft-com:office:office" xmlns:x="urn:schemas-microsoft-com:office:excel">
+<o:shapelayout v:ext="edit"><o:idmap v:ext="edit" data="%s"/></o:shapelayout>
+<v:shapetype id="_x0000_t202" coordsize="21600,21600" o:spt="202" path="m,l,21600r21600,l21600,xe"><v:stroke joinstyle="miter"/><v:path gradientshapeok="t" o:connecttype="rect"/></v:shapetype>
+`, strings.Join(blocks, ","))
+	for i, c := range comments {
+		fmt.Fprintf(w, `<v:shape id="_x0000_s%d" type="#_x0000_t202" style="position:absolute;margin-left:59.25pt;margin-top:1.5pt;width:108pt;height:59.25pt;z-index:%d;visibility:hidden" fillcolor="#ffffe1" o:insetmode="auto">`,
+			firstBlock*vmlBlockSize+i+1,
+			i+1,
+		)
+		w.WriteString(`<v:fill color2="#ffffe1"/><v:shadow on="t" color="black" obscured="t"/><v:path o:connecttype="none"/><v:textbox style="mso-direction-alt:auto"><div style="text-align:left"></div></v:textbox>`)
+		fmt.Fprintf(w, `<x:ClientData ObjectType="Note"><x:MoveWithCells/><x:SizeWithCells/><x:Anchor>%d, 15, %d, 10, %d, 15, %d, 4</x:Anchor><x:AutoFill>False</x:AutoFill><x:Row>%d</x:Row><x:Column>%d</x:Column></x:ClientData>`,
+			c.col+1, c.row, c.col+3, c.row+4,
+			c.row, c.col,
+		)
+		w.WriteString(`</v:shape>
+`)
+	}
+	w.WriteString(`</xml>`)
+	return lastBlock + 1, w.Flush()
+}
@@ -0,0 +1,243 @@
+package streamxlsx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Types of conditional formatting rules.
+const (
+	CondCellIs          = "cellIs"
+	CondExpression      = "expression"
+	CondColorScale      = "colorScale"
+	CondDataBar         = "dataBar"
+	CondIconSet         = "iconSet"
+	CondTop10           = "top10"
+	CondDuplicateValues = "duplicateValues"
+)
+
+// ConditionalRule is a single rule for conditional formatting. See
+// AddConditionalFormatting(). Which fields are used depends on the Type:
+//
+//	CondCellIs: Operator (one of the Operator* constants), Formula (1 or 2 values), Format
+//	CondExpression: Formula (1 value, such as "$B2<0"), Format
+//	CondColorScale: Colors (2 or 3 colors, for the min, (midpoint,) and max)
+//	CondDataBar: Color
+//	CondIconSet: IconSet (such as "3TrafficLights1", "4Arrows", or "5Rating")
+//	CondTop10: Rank, Percent, Bottom, Format
+//	CondDuplicateValues: Format
+//
+// Colors are "RRGGBB".
+type ConditionalRule struct {
+	Type       string
+	Operator   string
+	Formula    []string
+	Format     *Dxf
+	Colors     []string
+	Color      string
+	IconSet    string
+	Rank       int
+	Percent    bool
+	Bottom     bool
+	StopIfTrue bool
+}
+
+type conditionalFormatting struct {
+	ref   string
+	rules []cfRule
+}
+
+type cfRule struct {
+	ConditionalRule
+	dxfID    int // -1 for none
+	priority int
+}
+
+// AddConditionalFormatting adds rules for the cells in ref (such as
+// "B2:B100") in the current sheet. Formats are registered in the Stylesheet.
+// Rules are written when the sheet is closed, so this can be called any time
+// before WriteSheet().
+func (s *StreamXLSX) AddConditionalFormatting(ref string, rules ...ConditionalRule) error {
+	if s.error != nil {
+		return s.error
+	}
+	sh, err := s.sheet()
+	if err != nil {
+		s.error = err
+		return err
+	}
+	if ref == "" {
+		s.error = errors.New("conditional formatting without ref")
+		return s.error
+	}
+
+	cf := conditionalFormatting{ref: ref}
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			s.error = err
+			return err
+		}
+		sh.cfPriority++
+		rule := cfRule{
+			ConditionalRule: r,
+			dxfID:           -1,
+			priority:        sh.cfPriority,
+		}
+		if r.Format != nil {
+			rule.dxfID = s.Styles.GetDxfID(*r.Format)
+		}
+		cf.rules = append(cf.rules, rule)
+	}
+	sh.conditionals = append(sh.conditionals, cf)
+	return nil
+}
+
+func (r ConditionalRule) validate() error {
+	switch r.Type {
+	case CondCellIs:
+		if r.Operator == "" {
+			return errors.New("cellIs rule without operator")
+		}
+		if !validOperator(r.Operator) {
+			return fmt.Errorf("invalid operator: %q", r.Operator)
+		}
+		want := 1
+		if r.Operator == OperatorBetween || r.Operator == OperatorNotBetween {
+			want = 2
+		}
+		if len(r.Formula) != want {
+			return fmt.Errorf("cellIs rule with operator %s needs %d formulas", r.Operator, want)
+		}
+	case CondExpression:
+		if len(r.Formula) != 1 {
+			return errors.New("expression rule needs a formula")
+		}
+	case CondColorScale:
+		if n := len(r.Colors); n != 2 && n != 3 {
+			return errors.New("color scale needs 2 or 3 colors")
+		}
+	case CondDataBar:
+		if r.Color == "" {
+			return errors.New("data bar without color")
+		}
+	case CondIconSet:
+		if _, err := iconCount(r.IconSet); err != nil {
+			return err
+		}
+	case CondTop10:
+		if r.Rank < 1 {
+			return errors.New("top10 rule needs a rank")
+		}
+	case CondDuplicateValues:
+	default:
+		return fmt.Errorf("invalid conditional formatting type: %q", r.Type)
+	}
+
+	for _, c := range r.Colors {
+		if err := validateColor(c); err != nil {
+			return err
+		}
+	}
+	optional := []string{r.Color}
+	if f := r.Format; f != nil {
+		optional = append(optional, f.FontColor, f.FillColor)
+	}
+	for _, c := range optional {
+		if c == "" {
+			continue
+		}
+		if err := validateColor(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// iconCount gives the number of icons in a set, which is in its name.
+func iconCount(set string) (int, error) {
+	if set == "" {
+		return 3, nil // 3TrafficLights1
+	}
+	switch set[0] {
+	case '3', '4', '5':
+		return int(set[0] - '0'), nil
+	default:
+		return 0, fmt.Errorf("invalid icon set: %q", set)
+	}
+}
+
+func encodeConditionalFormatting(w *bufio.Writer, cfs []conditionalFormatting) {
+	for _, cf := range cfs {
+		w.WriteString(`<conditionalFormatting`)
+		writeAttr(w, "sqref", cf.ref)
+		w.WriteString(`>`)
+		for _, r := range cf.rules {
+			encodeCfRule(w, r)
+		}
+		w.WriteString(`</conditionalFormatting>`)
+	}
+}
+
+func encodeCfRule(w *bufio.Writer, r cfRule) {
+	fmt.Fprintf(w, `<cfRule type="%s"`, r.Type)
+	if r.dxfID >= 0 {
+		fmt.Fprintf(w, ` dxfId="%d"`, r.dxfID)
+	}
+	fmt.Fprintf(w, ` priority="%d"`, r.priority)
+	if r.StopIfTrue {
+		w.WriteString(` stopIfTrue="1"`)
+	}
+	if r.Type == CondCellIs {
+		writeAttr(w, "operator", r.Operator)
+	}
+	if r.Type == CondTop10 {
+		fmt.Fprintf(w, ` rank="%d"`, r.Rank)
+		if r.Percent {
+			w.WriteString(` percent="1"`)
+		}
+		if r.Bottom {
+			w.WriteString(` bottom="1"`)
+		}
+	}
+	w.WriteString(`>`)
+
+	for _, f := range r.Formula {
+		writeElem(w, "formula", f)
+	}
+	switch r.Type {
+	case CondColorScale:
+		w.WriteString(`<colorScale><cfvo type="min"/>`)
+		if len(r.Colors) == 3 {
+			w.WriteString(`<cfvo type="percentile" val="50"/>`)
+		}
+		w.WriteString(`<cfvo type="max"/>`)
+		for _, c := range r.Colors {
+			writeColor(w, c)
+		}
+		w.WriteString(`</colorScale>`)
+	case CondDataBar:
+		w.WriteString(`<dataBar><cfvo type="min"/><cfvo type="max"/>`)
+		writeColor(w, r.Color)
+		w.WriteString(`</dataBar>`)
+	case CondIconSet:
+		w.WriteString(`<iconSet`)
+		if r.IconSet != "" {
+			writeAttr(w, "iconSet", r.IconSet)
+		}
+		w.WriteString(`>`)
+		n, _ := iconCount(r.IconSet)
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(w, `<cfvo type="percent" val="%s"/>`, strconv.Itoa(i*100/n))
+		}
+		w.WriteString(`</iconSet>`)
+	}
+	w.WriteString(`</cfRule>`)
+}
+
+func writeColor(w *bufio.Writer, rgb string) {
+	w.WriteString(`<color`)
+	writeAttr(w, "rgb", argb(rgb))
+	w.WriteString(`/>`)
+}
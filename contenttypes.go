@@ -5,7 +5,31 @@ import (
 	"io"
 )
 
-func writeContentTypes(fh io.Writer, sheetCount int) error {
+// contentTypes are the types of the extra parts in the file, besides the
+// sheets.
+type contentTypes struct {
+	defaults  []contentType // by extension
+	overrides []contentType // by part name
+}
+
+type contentType struct {
+	name, typ string
+}
+
+func (c *contentTypes) addDefault(ext, typ string) {
+	for _, d := range c.defaults {
+		if d.name == ext {
+			return
+		}
+	}
+	c.defaults = append(c.defaults, contentType{ext, typ})
+}
+
+func (c *contentTypes) addOverride(part, typ string) {
+	c.overrides = append(c.overrides, contentType{part, typ})
+}
+
+func writeContentTypes(fh io.Writer, sheetCount int, parts contentTypes) error {
 	fh.Write([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
 <Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
 <Override PartName="/_rels/.rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
@@ -22,6 +46,12 @@ func writeContentTypes(fh io.Writer, sheetCount int) error {
 			i+1,
 		)
 	}
+	for _, d := range parts.defaults {
+		fmt.Fprintf(fh, `<Default Extension="%s" ContentType="%s"/>`, d.name, d.typ)
+	}
+	for _, o := range parts.overrides {
+		fmt.Fprintf(fh, `<Override PartName="%s" ContentType="%s"/>`, o.name, o.typ)
+	}
 	_, err := fh.Write([]byte(`</Types>`))
 	return err
 }
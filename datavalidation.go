@@ -0,0 +1,196 @@
+package streamxlsx
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Types of data validation.
+const (
+	ValidationList       = "list"
+	ValidationWhole      = "whole"
+	ValidationDecimal    = "decimal"
+	ValidationDate       = "date"
+	ValidationTime       = "time"
+	ValidationTextLength = "textLength"
+	ValidationCustom     = "custom"
+)
+
+// Operators for data validation. The default is "between".
+const (
+	OperatorBetween            = "between"
+	OperatorNotBetween         = "notBetween"
+	OperatorEqual              = "equal"
+	OperatorNotEqual           = "notEqual"
+	OperatorLessThan           = "lessThan"
+	OperatorLessThanOrEqual    = "lessThanOrEqual"
+	OperatorGreaterThan        = "greaterThan"
+	OperatorGreaterThanOrEqual = "greaterThanOrEqual"
+)
+
+// DataValidation restricts what can be entered in cells. See
+// AddDataValidation().
+//
+// Formula1 and Formula2 are the values for the Operator, such as "0" and "100"
+// for a whole number between 0 and 100. Dates can be given as "DATE(2020,1,31)".
+// For a list Formula1 is a range ("$A$1:$A$5", or a defined name), or use
+// List for literal values.
+type DataValidation struct {
+	Ref                string // the cells to validate, such as "B2:B100"
+	Type               string // one of the Validation* constants
+	Operator           string // one of the Operator* constants
+	List               []string
+	Formula1, Formula2 string
+	AllowBlank         bool
+	// Shown when a cell is selected.
+	InputTitle, InputMessage string
+	// Shown for invalid values.
+	ErrorTitle, ErrorMessage string
+	ErrorStyle               string // "stop" (the default), "warning", or "information"
+}
+
+// ListValidation makes a dropdown with the given values.
+func ListValidation(ref string, values ...string) DataValidation {
+	return DataValidation{
+		Ref:  ref,
+		Type: ValidationList,
+		List: values,
+	}
+}
+
+// AddDataValidation adds a validation to the current sheet. Validations are
+// written when the sheet is closed, so this can be called any time before
+// WriteSheet().
+func (s *StreamXLSX) AddDataValidation(dv DataValidation) error {
+	if s.error != nil {
+		return s.error
+	}
+	if err := dv.validate(); err != nil {
+		s.error = err
+		return err
+	}
+	sh, err := s.sheet()
+	if err != nil {
+		s.error = err
+		return err
+	}
+	sh.validations = append(sh.validations, dv)
+	return nil
+}
+
+func (dv DataValidation) validate() error {
+	if dv.Ref == "" {
+		return errors.New("data validation without ref")
+	}
+	if dv.Operator != "" && !validOperator(dv.Operator) {
+		return fmt.Errorf("invalid operator: %q", dv.Operator)
+	}
+	switch dv.ErrorStyle {
+	case "", "stop", "warning", "information":
+	default:
+		return fmt.Errorf("invalid error style: %q", dv.ErrorStyle)
+	}
+	switch dv.Type {
+	case ValidationList:
+		if len(dv.List) == 0 && dv.Formula1 == "" {
+			return errors.New("list validation without values")
+		}
+		for _, v := range dv.List {
+			if strings.Contains(v, ",") {
+				return fmt.Errorf("list value with a comma: %q", v)
+			}
+		}
+		// the limit of Excel
+		if n := len(strings.Join(dv.List, ",")); n > 255 {
+			return fmt.Errorf("list values are too long: %d characters", n)
+		}
+	case ValidationWhole, ValidationDecimal, ValidationDate, ValidationTime, ValidationTextLength, ValidationCustom:
+		if dv.Formula1 == "" {
+			return fmt.Errorf("%s validation without Formula1", dv.Type)
+		}
+		if (dv.Operator == "" || dv.Operator == OperatorBetween || dv.Operator == OperatorNotBetween) && dv.Type != ValidationCustom && dv.Formula2 == "" {
+			return fmt.Errorf("%s validation without Formula2", dv.Type)
+		}
+	default:
+		return fmt.Errorf("invalid data validation type: %q", dv.Type)
+	}
+	return nil
+}
+
+// validOperator is true for the Operator* constants.
+func validOperator(op string) bool {
+	switch op {
+	case OperatorBetween, OperatorNotBetween, OperatorEqual, OperatorNotEqual,
+		OperatorLessThan, OperatorLessThanOrEqual, OperatorGreaterThan, OperatorGreaterThanOrEqual:
+		return true
+	default:
+		return false
+	}
+}
+
+func encodeDataValidations(w *bufio.Writer, dvs []DataValidation) {
+	if len(dvs) == 0 {
+		return
+	}
+	fmt.Fprintf(w, `<dataValidations count="%d">`, len(dvs))
+	for _, dv := range dvs {
+		fmt.Fprintf(w, `<dataValidation type="%s"`, dv.Type)
+		if dv.Operator != "" {
+			writeAttr(w, "operator", dv.Operator)
+		}
+		if dv.ErrorStyle != "" {
+			writeAttr(w, "errorStyle", dv.ErrorStyle)
+		}
+		if dv.AllowBlank {
+			w.WriteString(` allowBlank="1"`)
+		}
+		if dv.InputTitle != "" || dv.InputMessage != "" {
+			w.WriteString(` showInputMessage="1"`)
+		}
+		w.WriteString(` showErrorMessage="1"`)
+		if dv.ErrorTitle != "" {
+			writeAttr(w, "errorTitle", dv.ErrorTitle)
+		}
+		if dv.ErrorMessage != "" {
+			writeAttr(w, "error", dv.ErrorMessage)
+		}
+		if dv.InputTitle != "" {
+			writeAttr(w, "promptTitle", dv.InputTitle)
+		}
+		if dv.InputMessage != "" {
+			writeAttr(w, "prompt", dv.InputMessage)
+		}
+		writeAttr(w, "sqref", dv.Ref)
+		w.WriteString(`>`)
+
+		f1 := dv.Formula1
+		if len(dv.List) > 0 {
+			f1 = `"` + strings.Join(dv.List, ",") + `"`
+		}
+		writeElem(w, "formula1", f1)
+		if dv.Formula2 != "" {
+			writeElem(w, "formula2", dv.Formula2)
+		}
+		w.WriteString(`</dataValidation>`)
+	}
+	w.WriteString(`</dataValidations>`)
+}
+
+// writeAttr writes ` key="value"`, with the value escaped.
+func writeAttr(w *bufio.Writer, key, value string) {
+	w.WriteString(` `)
+	w.WriteString(key)
+	w.WriteString(`="`)
+	xml.EscapeText(w, []byte(value))
+	w.WriteString(`"`)
+}
+
+// writeElem writes `<key>value</key>`, with the value escaped.
+func writeElem(w *bufio.Writer, key, value string) {
+	w.WriteString(`<` + key + `>`)
+	xml.EscapeText(w, []byte(value))
+	w.WriteString(`</` + key + `>`)
+}
@@ -0,0 +1,213 @@
+package streamxlsx
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Properties are the document's metadata, as shown by Excel and file
+// browsers. See StreamXLSX.Properties.
+type Properties struct {
+	Title       string
+	Subject     string
+	Creator     string // the author
+	Keywords    string
+	Description string
+	Category    string
+	// Created defaults to the time Close() is called. Modified defaults to
+	// Created.
+	Created, Modified time.Time
+	Company           string
+	Application       string // the name of the program which made the file
+}
+
+// w3cdtf is the date format in core.xml.
+const w3cdtf = "2006-01-02T15:04:05Z"
+
+// maxCustomPropertyName is the longest name of a custom property.
+const maxCustomPropertyName = 255
+
+// customProperty is a property in docProps/custom.xml.
+type customProperty struct {
+	name       string
+	typ, value string // vt: type, and encoded value
+}
+
+// SetCustomProperty sets a custom document property, which can be read by
+// other tools. Supported values are strings, all ints and uints, floats,
+// bools, and time.Time. Setting an existing name (case insensitive) replaces
+// its value. They are written on Close().
+func (s *StreamXLSX) SetCustomProperty(name string, value interface{}) error {
+	if s.error != nil {
+		return s.error
+	}
+	p, err := newCustomProperty(name, value)
+	if err != nil {
+		s.error = err
+		return err
+	}
+	for i, c := range s.customProperties {
+		if strings.EqualFold(c.name, name) {
+			s.customProperties[i] = p
+			return nil
+		}
+	}
+	s.customProperties = append(s.customProperties, p)
+	return nil
+}
+
+func newCustomProperty(name string, value interface{}) (customProperty, error) {
+	if name == "" || utf8.RuneCountInString(name) > maxCustomPropertyName {
+		return customProperty{}, fmt.Errorf("invalid custom property name: %q", name)
+	}
+	p := customProperty{name: name}
+	switch v := value.(type) {
+	case string:
+		p.typ, p.value = "lpwstr", v
+	case int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64:
+		p.typ, p.value = "i4", fmt.Sprintf("%d", v)
+		if n, err := strconv.ParseInt(p.value, 10, 64); err != nil || n < math.MinInt32 || n > math.MaxInt32 {
+			// too big for an i4
+			p.typ = "r8"
+		}
+	case float32:
+		p.typ, p.value = "r8", strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		p.typ, p.value = "r8", strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		p.typ, p.value = "bool", strconv.FormatBool(v)
+	case time.Time:
+		p.typ, p.value = "filetime", v.UTC().Format(w3cdtf)
+	default:
+		return customProperty{}, fmt.Errorf("unsupported custom property type: %T", v)
+	}
+	return p, nil
+}
+
+// writeDocProps writes docProps/core.xml and docProps/app.xml.
+func (s *StreamXLSX) writeDocProps() error {
+	if s.Properties == nil {
+		return nil
+	}
+	p := *s.Properties // don't change the caller's struct
+	if p.Created.IsZero() {
+		p.Created = time.Now()
+	}
+	if p.Modified.IsZero() {
+		p.Modified = p.Created
+	}
+
+	fh, err := s.zip.Create("docProps/core.xml")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(fh)
+	w.WriteString(xml.Header)
+	w.WriteString(`<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:dcmitype="http://purl.org/dc/dcmitype/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">`)
+	for _, e := range []struct {
+		tag, value string
+	}{
+		{"dc:title", p.Title},
+		{"dc:subject", p.Subject},
+		{"dc:creator", p.Creator},
+		{"cp:keywords", p.Keywords},
+		{"dc:description", p.Description},
+		{"cp:category", p.Category},
+	} {
+		if e.value != "" {
+			writeElem(w, e.tag, e.value)
+		}
+	}
+	w.WriteString(`<dcterms:created xsi:type="dcterms:W3CDTF">` + p.Created.UTC().Format(w3cdtf) + `</dcterms:created>`)
+	w.WriteString(`<dcterms:modified xsi:type="dcterms:W3CDTF">` + p.Modified.UTC().Format(w3cdtf) + `</dcterms:modified>`)
+	w.WriteString(`</cp:coreProperties>`)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fh, err = s.zip.Create("docProps/app.xml")
+	if err != nil {
+		return err
+	}
+	w = bufio.NewWriter(fh)
+	w.WriteString(xml.Header)
+	w.WriteString(`<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/extended-properties" xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes">`)
+	if p.Application != "" {
+		writeElem(w, "Application", p.Application)
+	}
+	if p.Company != "" {
+		writeElem(w, "Company", p.Company)
+	}
+	w.WriteString(`</Properties>`)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	s.parts.addOverride("/docProps/core.xml", "application/vnd.openxmlformats-package.core-properties+xml")
+	s.parts.addOverride("/docProps/app.xml", "application/vnd.openxmlformats-officedocument.extended-properties+xml")
+	return nil
+}
+
+// writeCustomProperties writes docProps/custom.xml.
+func (s *StreamXLSX) writeCustomProperties() error {
+	if len(s.customProperties) == 0 {
+		return nil
+	}
+	fh, err := s.zip.Create("docProps/custom.xml")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(fh)
+	w.WriteString(xml.Header)
+	w.WriteString(`<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/custom-properties" xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes">`)
+	for i, p := range s.customProperties {
+		// all custom properties have this fmtid, and pids start at 2
+		fmt.Fprintf(w, `<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="%d"`, i+2)
+		writeAttr(w, "name", p.name)
+		w.WriteString(`>`)
+		writeElem(w, "vt:"+p.typ, p.value)
+		w.WriteString(`</property>`)
+	}
+	w.WriteString(`</Properties>`)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	s.parts.addOverride("/docProps/custom.xml", "application/vnd.openxmlformats-officedocument.custom-properties+xml")
+	return nil
+}
+
+// docPropsRelations are the package relationships for writeDocProps() and
+// writeCustomProperties().
+func (s *StreamXLSX) docPropsRelations() []relationship {
+	var rels []relationship
+	if s.Properties != nil {
+		rels = append(rels,
+			relationship{
+				ID:     "rId2",
+				Target: "/docProps/core.xml",
+				Type:   "http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties",
+			},
+			relationship{
+				ID:     "rId3",
+				Target: "/docProps/app.xml",
+				Type:   "http://schemas.openxmlformats.org/officeDocument/2006/relationships/extended-properties",
+			},
+		)
+	}
+	if len(s.customProperties) > 0 {
+		rels = append(rels, relationship{
+			ID:     "rId4",
+			Target: "/docProps/custom.xml",
+			Type:   "http://schemas.openxmlformats.org/officeDocument/2006/relationships/custom-properties",
+		})
+	}
+	return rels
+}
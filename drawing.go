@@ -0,0 +1,254 @@
+package streamxlsx
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/xml"
+	"fmt"
+	"image"
+	_ "image/gif" // for DecodeConfig
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"strings"
+)
+
+// EMUs per pixel, at 96 DPI.
+const emuPerPixel = 9525
+
+// Image is a picture in a sheet. See AddImage().
+type Image struct {
+	Data []byte // png, jpeg, or gif
+	// Ref is the cell of the top left corner, such as "B2".
+	Ref string
+	// Width and Height are in pixels. They default to the size of the image.
+	Width, Height int
+	// ToRef, if set, is the cell of the bottom right corner, and the image is
+	// stretched between Ref and ToRef. Width and Height are ignored.
+	ToRef string
+	// Description is the alternative text.
+	Description string
+}
+
+// anchor is something placed in the drawing of a sheet.
+type anchor struct {
+	from, to  [2]int // col, row; 0-based
+	twoCell   bool
+	cx, cy    int // size in EMU, for a one cell anchor
+	relID     string
+	name      string
+	descr     string
+	imageData []byte // to write in xl/media/
+	imageExt  string
+	chart     *Chart
+}
+
+// drawing has the images and charts of a sheet.
+type drawing struct {
+	anchors   []anchor
+	relations []relationship
+}
+
+// AddImage places an image in the current sheet. The image is written when
+// the sheet is closed, so this can be called any time before WriteSheet().
+func (s *StreamXLSX) AddImage(img Image) error {
+	if s.error != nil {
+		return s.error
+	}
+	sh, err := s.sheet()
+	if err != nil {
+		s.error = err
+		return err
+	}
+	a, err := imageAnchor(img)
+	if err != nil {
+		s.error = err
+		return err
+	}
+	sh.addAnchor(a)
+	return nil
+}
+
+func imageAnchor(img Image) (anchor, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(img.Data))
+	if err != nil {
+		return anchor{}, fmt.Errorf("image: %w", err)
+	}
+	a := anchor{
+		descr:     img.Description,
+		imageData: img.Data,
+		imageExt:  format,
+	}
+	if a.from[0], a.from[1], err = parseRef(img.Ref); err != nil {
+		return anchor{}, err
+	}
+	if img.ToRef != "" {
+		a.twoCell = true
+		if a.to[0], a.to[1], err = parseRef(img.ToRef); err != nil {
+			return anchor{}, err
+		}
+		if a.to[0] < a.from[0] || a.to[1] < a.from[1] {
+			return anchor{}, fmt.Errorf("image: %s is not below/right of %s", img.ToRef, img.Ref)
+		}
+		return a, nil
+	}
+	w, h := img.Width, img.Height
+	if w == 0 {
+		w = cfg.Width
+	}
+	if h == 0 {
+		h = cfg.Height
+	}
+	a.cx, a.cy = w*emuPerPixel, h*emuPerPixel
+	return a, nil
+}
+
+// addAnchor adds something to the drawing, and creates the drawing the first
+// time.
+func (sh *sheetEncoder) addAnchor(a anchor) {
+	if sh.drawing == nil {
+		sh.drawing = &drawing{}
+		sh.relations = append(sh.relations, relationship{
+			ID:     "drawingId1",
+			Target: fmt.Sprintf("/xl/drawings/drawing%d.xml", sh.id),
+			Type:   "http://schemas.openxmlformats.org/officeDocument/2006/relationships/drawing",
+		})
+	}
+	d := sh.drawing
+	n := len(d.anchors) + 1
+	a.relID = fmt.Sprintf("rId%d", n)
+	if a.chart != nil {
+		a.name = fmt.Sprintf("Chart %d", n)
+	} else {
+		a.name = fmt.Sprintf("Picture %d", n)
+	}
+	d.anchors = append(d.anchors, a)
+}
+
+func encodeDrawing(w *bufio.Writer, d *drawing) {
+	if d == nil {
+		return
+	}
+	w.WriteString(`<drawing r:id="drawingId1"/>`)
+}
+
+// writeDrawingParts writes the images of the drawing in xl/media/, and its
+// charts in xl/charts/, and sets the drawing relationships. Identical images
+// are written once per workbook.
+func (s *StreamXLSX) writeDrawingParts(d *drawing) error {
+	for _, a := range d.anchors {
+		if a.chart != nil {
+			s.chartCount++
+			target := fmt.Sprintf("/xl/charts/chart%d.xml", s.chartCount)
+			fh, err := s.zip.Create(target[1:])
+			if err != nil {
+				return err
+			}
+			if err := writeChart(fh, *a.chart); err != nil {
+				return err
+			}
+			s.parts.addOverride(target, "application/vnd.openxmlformats-officedocument.drawingml.chart+xml")
+			d.relations = append(d.relations, relationship{
+				ID:     a.relID,
+				Target: target,
+				Type:   "http://schemas.openxmlformats.org/officeDocument/2006/relationships/chart",
+			})
+			continue
+		}
+
+		sum := sha256.Sum256(a.imageData)
+		target, ok := s.media[sum]
+		if !ok {
+			target = fmt.Sprintf("/xl/media/image%d.%s", len(s.media)+1, a.imageExt)
+			fh, err := s.zip.Create(target[1:])
+			if err != nil {
+				return err
+			}
+			if _, err := fh.Write(a.imageData); err != nil {
+				return err
+			}
+			s.media[sum] = target
+			s.parts.addDefault(a.imageExt, "image/"+a.imageExt)
+		}
+		d.relations = append(d.relations, relationship{
+			ID:     a.relID,
+			Target: target,
+			Type:   "http://schemas.openxmlformats.org/officeDocument/2006/relationships/image",
+		})
+	}
+	return nil
+}
+
+func writeDrawing(fh io.Writer, d *drawing) error {
+	w := bufio.NewWriter(fh)
+	w.WriteString(xml.Header)
+	w.WriteString(`<xdr:wsDr xmlns:xdr="http://schemas.openxmlformats.org/drawingml/2006/spreadsheetDrawing" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+	for i, a := range d.anchors {
+		if a.twoCell {
+			w.WriteString(`<xdr:twoCellAnchor editAs="oneCell">`)
+			encodeAnchorPos(w, "from", a.from)
+			encodeAnchorPos(w, "to", a.to)
+		} else {
+			w.WriteString(`<xdr:oneCellAnchor>`)
+			encodeAnchorPos(w, "from", a.from)
+			fmt.Fprintf(w, `<xdr:ext cx="%d" cy="%d"/>`, a.cx, a.cy)
+		}
+
+		if a.chart != nil {
+			encodeChartFrame(w, i+2, a)
+		} else {
+			encodePicture(w, i+2, a)
+		}
+
+		w.WriteString(`<xdr:clientData/>`)
+		if a.twoCell {
+			w.WriteString(`</xdr:twoCellAnchor>`)
+		} else {
+			w.WriteString(`</xdr:oneCellAnchor>`)
+		}
+	}
+	w.WriteString(`</xdr:wsDr>`)
+	return w.Flush()
+}
+
+func encodePicture(w *bufio.Writer, id int, a anchor) {
+	w.WriteString(`<xdr:pic><xdr:nvPicPr>`)
+	fmt.Fprintf(w, `<xdr:cNvPr id="%d"`, id)
+	writeAttr(w, "name", a.name)
+	if a.descr != "" {
+		writeAttr(w, "descr", a.descr)
+	}
+	w.WriteString(`/><xdr:cNvPicPr><a:picLocks noChangeAspect="1"/></xdr:cNvPicPr></xdr:nvPicPr>`)
+	fmt.Fprintf(w, `<xdr:blipFill><a:blip r:embed="%s"/><a:stretch><a:fillRect/></a:stretch></xdr:blipFill>`, a.relID)
+	fmt.Fprintf(w, `<xdr:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="%d" cy="%d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></xdr:spPr>`, a.cx, a.cy)
+	w.WriteString(`</xdr:pic>`)
+}
+
+func encodeAnchorPos(w *bufio.Writer, tag string, pos [2]int) {
+	fmt.Fprintf(w, `<xdr:%s><xdr:col>%d</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>%d</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:%s>`, tag, pos[0], pos[1], tag)
+}
+
+// parseRef parses an "A1" style ref, which can have "$"s. Returns 0-based
+// column and row.
+func parseRef(ref string) (int, int, error) {
+	r := strings.ToUpper(strings.ReplaceAll(ref, "$", ""))
+	col, i := 0, 0
+	for ; i < len(r) && r[i] >= 'A' && r[i] <= 'Z'; i++ {
+		col = col*26 + int(r[i]-'A'+1)
+		if col > MaxColumns {
+			break
+		}
+	}
+	row, j := 0, i
+	for ; j < len(r) && r[j] >= '0' && r[j] <= '9'; j++ {
+		row = row*10 + int(r[j]-'0')
+		if row > MaxRows {
+			break
+		}
+	}
+	if i == 0 || j == i || j != len(r) || col > MaxColumns || row < 1 || row > MaxRows {
+		return 0, 0, fmt.Errorf("invalid cell ref: %q", ref)
+	}
+	return col - 1, row - 1, nil
+}
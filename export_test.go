@@ -0,0 +1,9 @@
+package streamxlsx
+
+// SetMaxRows lowers the row limit, so tests don't need to write a million
+// rows. It returns a func which restores the limit.
+func SetMaxRows(n int) func() {
+	old := maxRows
+	maxRows = n
+	return func() { maxRows = old }
+}
@@ -0,0 +1,191 @@
+package streamxlsx
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// With spilling the URL -> relationship ID cache is reset after this many
+// entries, to keep memory use flat.
+const maxSpillLinkIDs = 10_000
+
+type hyperlink struct {
+	RelID    string `xml:"r:id,attr"`
+	Ref      string `xml:"ref,attr"`
+	Location string `xml:"location,attr"`
+	Display  string `xml:"display,attr"`
+	Tooltip  string `xml:"tooltip,attr"`
+	url      string
+}
+
+// linkBuffer has the hyperlinks of a sheet, and their relationships, until
+// they can be written after the sheet data. With spill set they are buffered
+// in temp files, otherwise in memory.
+type linkBuffer struct {
+	spill     bool
+	links     []hyperlink
+	rels      []relationship
+	linkFile  *spillFile
+	relFile   *spillFile
+	linkCount int
+	relCount  int
+	ids       map[string]string // URL -> relationship ID
+}
+
+func (lb *linkBuffer) add(link hyperlink, url string) error {
+	if url != "" {
+		id, err := lb.relationID(url)
+		if err != nil {
+			return err
+		}
+		link.RelID = id
+	}
+
+	lb.linkCount++
+	if !lb.spill {
+		lb.links = append(lb.links, link)
+		return nil
+	}
+	if lb.linkFile == nil {
+		f, err := newSpillFile()
+		if err != nil {
+			return err
+		}
+		lb.linkFile = f
+	}
+	encodeHyperlink(lb.linkFile.w, link)
+	return nil
+}
+
+// relationID gives the relationship for an URL, which is created if needed.
+func (lb *linkBuffer) relationID(url string) (string, error) {
+	if id, ok := lb.ids[url]; ok {
+		return id, nil
+	}
+	if lb.ids == nil || (lb.spill && len(lb.ids) >= maxSpillLinkIDs) {
+		lb.ids = map[string]string{}
+	}
+
+	lb.relCount++
+	rel := relationship{
+		ID:         fmt.Sprintf("linkId%d", lb.relCount),
+		Type:       "http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink",
+		Target:     url,
+		TargetMode: "External",
+	}
+	lb.ids[url] = rel.ID
+	if !lb.spill {
+		lb.rels = append(lb.rels, rel)
+		return rel.ID, nil
+	}
+	if lb.relFile == nil {
+		f, err := newSpillFile()
+		if err != nil {
+			return "", err
+		}
+		lb.relFile = f
+	}
+	return rel.ID, encodeRelationship(lb.relFile.w, rel)
+}
+
+// encode writes the <hyperlinks> section.
+func (lb *linkBuffer) encode(w *bufio.Writer) error {
+	if lb.linkCount == 0 {
+		return nil
+	}
+	w.WriteString(`<hyperlinks>`)
+	for _, link := range lb.links {
+		encodeHyperlink(w, link)
+	}
+	if lb.linkFile != nil {
+		if err := lb.linkFile.copyTo(w); err != nil {
+			return err
+		}
+	}
+	w.WriteString(`</hyperlinks>`)
+	return nil
+}
+
+// encodeRelations writes the <Relationship>s for the links.
+func (lb *linkBuffer) encodeRelations(w io.Writer) error {
+	for _, rel := range lb.rels {
+		if err := encodeRelationship(w, rel); err != nil {
+			return err
+		}
+	}
+	if lb.relFile != nil {
+		return lb.relFile.copyTo(w)
+	}
+	return nil
+}
+
+// remove the temp files, if any.
+func (lb *linkBuffer) remove() {
+	if lb.linkFile != nil {
+		lb.linkFile.remove()
+		lb.linkFile = nil
+	}
+	if lb.relFile != nil {
+		lb.relFile.remove()
+		lb.relFile = nil
+	}
+}
+
+func encodeHyperlink(w *bufio.Writer, link hyperlink) {
+	w.WriteString(`<hyperlink ref="`)
+	xml.EscapeText(w, []byte(link.Ref))
+	if link.RelID != "" {
+		w.WriteString(`" r:id="`)
+		xml.EscapeText(w, []byte(link.RelID))
+	}
+	if link.Location != "" {
+		w.WriteString(`" location="`)
+		xml.EscapeText(w, []byte(link.Location))
+	}
+	if link.Display != "" {
+		w.WriteString(`" display="`)
+		xml.EscapeText(w, []byte(link.Display))
+	}
+	if link.Tooltip != "" {
+		w.WriteString(`" tooltip="`)
+		xml.EscapeText(w, []byte(link.Tooltip))
+	}
+	w.WriteString(`"/>`)
+}
+
+// spillFile is a temp file to buffer XML fragments.
+type spillFile struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+func newSpillFile() (*spillFile, error) {
+	f, err := ioutil.TempFile("", "streamxlsx")
+	if err != nil {
+		return nil, err
+	}
+	return &spillFile{
+		f: f,
+		w: bufio.NewWriter(f),
+	}, nil
+}
+
+func (s *spillFile) copyTo(w io.Writer) error {
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, s.f)
+	return err
+}
+
+func (s *spillFile) remove() {
+	s.f.Close()
+	os.Remove(s.f.Name())
+}
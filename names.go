@@ -0,0 +1,172 @@
+package streamxlsx
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefinedName is a name for a constant, a range, or a formula, which can be
+// used in formulas. See AddDefinedName().
+type DefinedName struct {
+	Name string
+	// Value is a constant ("0.21"), a range ("'Prices'!$A$2:$B$10"), or a
+	// formula ("SUM('Prices'!$B:$B)").
+	Value string
+	// Local names can only be used on the current sheet.
+	Local bool
+	// DataRange makes the value the range of all rows and columns written in
+	// the current sheet, which is known when the sheet is closed. Value should
+	// be empty.
+	DataRange bool
+	Comment   string
+	Hidden    bool
+}
+
+// AddDefinedName adds a name to the workbook. Names are letters, digits,
+// "_", "\", and "." (but can't start with a digit or ".", and can't look like
+// a cell ref), and are case insensitive.
+// Global names can be used on any sheet, and need to be unique in the
+// workbook, including table names. Local names are for the current sheet, and
+// take precedence over global names.
+func (s *StreamXLSX) AddDefinedName(n DefinedName) error {
+	if s.error != nil {
+		return s.error
+	}
+	if err := s.addDefinedName(n); err != nil {
+		s.error = err
+		return err
+	}
+	return nil
+}
+
+func (s *StreamXLSX) addDefinedName(n DefinedName) error {
+	if err := validateDefinedName(n.Name); err != nil {
+		return err
+	}
+	if strings.HasPrefix(strings.ToLower(n.Name), "_xlnm.") {
+		return fmt.Errorf("reserved name: %q", n.Name)
+	}
+	n.Value = strings.TrimPrefix(n.Value, "=")
+	switch {
+	case n.DataRange && n.Value != "":
+		return errors.New("defined name with both a value and a data range")
+	case !n.DataRange && n.Value == "":
+		return fmt.Errorf("defined name %q without a value", n.Name)
+	}
+	if s.nameTaken(n.Name, n.Local) {
+		return fmt.Errorf("duplicate defined name: %q", n.Name)
+	}
+
+	if !n.Local && !n.DataRange {
+		s.definedNames = append(s.definedNames, definedName{
+			name:    n.Name,
+			sheet:   -1,
+			value:   n.Value,
+			comment: n.Comment,
+			hidden:  n.Hidden,
+		})
+		return nil
+	}
+	// needs the sheet, so it's added when the sheet is closed
+	sh, err := s.sheet()
+	if err != nil {
+		return err
+	}
+	sh.names = append(sh.names, n)
+	return nil
+}
+
+// nameTaken checks for a global name, or a local name on the open sheet.
+func (s *StreamXLSX) nameTaken(name string, local bool) bool {
+	var taken []string
+	if !local {
+		taken = append(taken, s.tableNames...)
+		for _, n := range s.definedNames {
+			if !n.local {
+				taken = append(taken, n.name)
+			}
+		}
+	}
+	if s.openSheet != nil {
+		for _, n := range s.openSheet.names {
+			if n.Local == local {
+				taken = append(taken, n.Name)
+			}
+		}
+	}
+	return sheetNameTaken(name, taken)
+}
+
+// addSheetNames adds the names which needed the open sheet, which will be the
+// next finished sheet.
+func (s *StreamXLSX) addSheetNames() {
+	sh := s.openSheet
+	for _, n := range sh.names {
+		d := definedName{
+			name:    n.Name,
+			sheet:   len(s.finishedSheets),
+			local:   n.Local,
+			value:   n.Value,
+			comment: n.Comment,
+			hidden:  n.Hidden,
+		}
+		if n.DataRange {
+			d.refs = []string{sh.dataRange()}
+		}
+		s.definedNames = append(s.definedNames, d)
+	}
+}
+
+// dataRange is the absolute range of everything written in the sheet.
+func (sh *sheetEncoder) dataRange() string {
+	cols, rows := sh.cols, sh.rows
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	return "$A$1:$" + asCol(cols-1) + "$" + strconv.Itoa(rows)
+}
+
+// validateDefinedName checks the rules for names used in formulas.
+func validateDefinedName(name string) error {
+	if name == "" || len(name) > 255 {
+		return fmt.Errorf("invalid name: %q", name)
+	}
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r) || r == '_' || r == '\\':
+		case i > 0 && (unicode.IsDigit(r) || r == '.'):
+		default:
+			return fmt.Errorf("invalid name: %q", name)
+		}
+	}
+	if looksLikeRef(name) {
+		return fmt.Errorf("invalid name: %q looks like a cell reference", name)
+	}
+	switch strings.ToUpper(name) {
+	case "R", "C":
+		return fmt.Errorf("invalid name: %q", name)
+	}
+	return nil
+}
+
+// looksLikeRef is true for "A1" and "R1C1" style refs.
+func looksLikeRef(name string) bool {
+	upper := strings.ToUpper(name)
+	letters := strings.TrimLeft(upper, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	if len(letters) < len(upper) && len(upper)-len(letters) <= 3 && letters != "" && strings.Trim(letters, "0123456789") == "" {
+		return true
+	}
+	if strings.HasPrefix(upper, "R") {
+		rest := strings.TrimLeft(upper[1:], "0123456789")
+		if strings.HasPrefix(rest, "C") && strings.Trim(rest[1:], "0123456789") == "" {
+			return true
+		}
+	}
+	return false
+}
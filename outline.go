@@ -0,0 +1,301 @@
+package streamxlsx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+)
+
+// MaxOutlineLevel is the deepest group level Excel supports.
+const MaxOutlineLevel = 7
+
+// defaultColumnWidth is what Excel uses for a default Calibri 11 sheet.
+const defaultColumnWidth = "9.140625"
+
+// ErrSheetStarted is returned when a setting which goes in the start of the
+// sheet XML is changed after the sheet was started.
+var ErrSheetStarted = errors.New("sheet already started")
+
+// Outline configures row and column grouping in a sheet. See SetOutline().
+type Outline struct {
+	// Summary rows are above their group. Default is below.
+	SummaryAbove bool
+	// Summary columns are left of their group. Default is right.
+	SummaryLeft bool
+	// The deepest row group level which will be used in the sheet, 1-7. Since
+	// the start of the sheet is written before the rows this needs to be set
+	// before using BeginRowGroup().
+	RowLevels int
+}
+
+// sheetHeader has the settings for the start of a sheet's XML, which need to
+// be known before the first row is written.
+type sheetHeader struct {
+	outline   *Outline
+	colGroups []colGroup
+	pageSetup *PageSetup
+	view      *SheetView
+}
+
+type colGroup struct {
+	first, last int
+	collapsed   bool
+}
+
+// rowGroup is a group opened with BeginRowGroup().
+type rowGroup struct {
+	collapsed bool
+}
+
+// SetOutline configures grouping for the current sheet. This needs to be
+// called before anything is written to the sheet, and applies until the next
+// WriteSheet().
+func (s *StreamXLSX) SetOutline(o Outline) error {
+	if s.error != nil {
+		return s.error
+	}
+	if s.openSheet != nil {
+		s.error = ErrSheetStarted
+		return s.error
+	}
+	if o.RowLevels < 0 || o.RowLevels > MaxOutlineLevel {
+		s.error = fmt.Errorf("invalid outline row levels: %d", o.RowLevels)
+		return s.error
+	}
+	s.header.outline = &o
+	return nil
+}
+
+// GroupColumns groups the (0-based, inclusive) columns. Groups can be nested
+// by calling this more than once, for example (0, 10) and (2, 4) gives column
+// C till E level 2. A collapsed group has its columns hidden.
+// Like SetOutline() this needs to be called before anything is written to the
+// sheet.
+func (s *StreamXLSX) GroupColumns(first, last int, collapsed bool) error {
+	if s.error != nil {
+		return s.error
+	}
+	if s.openSheet != nil {
+		s.error = ErrSheetStarted
+		return s.error
+	}
+	if first < 0 || last < first || last >= MaxColumns {
+		s.error = fmt.Errorf("invalid column group: %d-%d", first, last)
+		return s.error
+	}
+	s.header.colGroups = append(s.header.colGroups, colGroup{first, last, collapsed})
+	if _, max := colLevels(s.header.colGroups); max > MaxOutlineLevel {
+		s.error = fmt.Errorf("column groups nested deeper than %d", MaxOutlineLevel)
+		return s.error
+	}
+	return nil
+}
+
+// BeginRowGroup starts a group of rows. All rows written until the matching
+// EndRowGroup() get the group's outline level. Groups can be nested, up to
+// Outline.RowLevels deep.
+// A collapsed group has its rows hidden. If summary rows are below the group
+// (the default), the next row after the group is marked as collapsed.
+func (s *StreamXLSX) BeginRowGroup(collapsed bool) error {
+	if s.error != nil {
+		return s.error
+	}
+	sh, err := s.sheet()
+	if err != nil {
+		s.error = err
+		return err
+	}
+	if err := sh.beginRowGroup(collapsed); err != nil {
+		s.error = err
+		return err
+	}
+	return nil
+}
+
+// EndRowGroup closes the group started with BeginRowGroup().
+func (s *StreamXLSX) EndRowGroup() error {
+	if s.error != nil {
+		return s.error
+	}
+	sh, err := s.sheet()
+	if err != nil {
+		s.error = err
+		return err
+	}
+	if err := sh.endRowGroup(); err != nil {
+		s.error = err
+		return err
+	}
+	return nil
+}
+
+func (sh *sheetEncoder) beginRowGroup(collapsed bool) error {
+	levels := 0
+	if o := sh.header.outline; o != nil {
+		levels = o.RowLevels
+	}
+	if len(sh.rowGroups) >= levels {
+		return fmt.Errorf("row groups nested deeper than Outline.RowLevels (%d)", levels)
+	}
+	sh.rowGroups = append(sh.rowGroups, rowGroup{collapsed: collapsed})
+	return nil
+}
+
+func (sh *sheetEncoder) endRowGroup() error {
+	n := len(sh.rowGroups)
+	if n == 0 {
+		return errors.New("no open row group")
+	}
+	g := sh.rowGroups[n-1]
+	sh.rowGroups = sh.rowGroups[:n-1]
+	if g.collapsed && !sh.header.outline.SummaryAbove {
+		sh.collapseNext = true
+	}
+	return nil
+}
+
+// applyRowGroups sets the outline options for a row in the open groups.
+func (sh *sheetEncoder) applyRowGroups(opts RowOptions) RowOptions {
+	if opts.OutlineLevel == 0 {
+		opts.OutlineLevel = len(sh.rowGroups)
+	}
+	for _, g := range sh.rowGroups {
+		if g.collapsed {
+			opts.Hidden = true
+		}
+	}
+	if sh.collapseNext {
+		opts.Collapsed = true
+		sh.collapseNext = false
+	}
+	return opts
+}
+
+// colLevels gives the outline level of every grouped column, and the max
+// level.
+func colLevels(groups []colGroup) (map[int]int, int) {
+	var (
+		levels = map[int]int{}
+		max    = 0
+	)
+	for _, g := range groups {
+		for c := g.first; c <= g.last; c++ {
+			levels[c]++
+			if levels[c] > max {
+				max = levels[c]
+			}
+		}
+	}
+	return levels, max
+}
+
+type colXML struct {
+	level             int
+	hidden, collapsed bool
+}
+
+// encodeCols writes the <cols> for the column groups.
+func encodeCols(w *bufio.Writer, h sheetHeader) {
+	if len(h.colGroups) == 0 {
+		return
+	}
+	levels, _ := colLevels(h.colGroups)
+	summaryLeft := h.outline != nil && h.outline.SummaryLeft
+
+	first, last := MaxColumns, 0
+	for _, g := range h.colGroups {
+		if g.first < first {
+			first = g.first
+		}
+		if g.last > last {
+			last = g.last
+		}
+	}
+	// the summary columns can be outside the groups
+	first, last = first-1, last+1
+	if first < 0 {
+		first = 0
+	}
+	if last >= MaxColumns {
+		last = MaxColumns - 1
+	}
+
+	cols := make([]colXML, last-first+1)
+	for c := range cols {
+		cols[c].level = levels[first+c]
+	}
+	for _, g := range h.colGroups {
+		if !g.collapsed {
+			continue
+		}
+		for c := g.first; c <= g.last; c++ {
+			cols[c-first].hidden = true
+		}
+		summary := g.last + 1
+		if summaryLeft {
+			summary = g.first - 1
+		}
+		if summary >= first && summary <= last {
+			cols[summary-first].collapsed = true
+		}
+	}
+
+	w.WriteString(`<cols>`)
+	for i := 0; i < len(cols); {
+		// consecutive equal columns go in a single <col>
+		j := i + 1
+		for j < len(cols) && cols[j] == cols[i] {
+			j++
+		}
+		if c := cols[i]; c != (colXML{}) {
+			fmt.Fprintf(w, `<col min="%d" max="%d" width="%s"`, first+i+1, first+j, defaultColumnWidth)
+			if c.hidden {
+				w.WriteString(` hidden="1"`)
+			}
+			if c.level > 0 {
+				fmt.Fprintf(w, ` outlineLevel="%d"`, c.level)
+			}
+			if c.collapsed {
+				w.WriteString(` collapsed="1"`)
+			}
+			w.WriteString(`/>`)
+		}
+		i = j
+	}
+	w.WriteString(`</cols>`)
+}
+
+// encodeOutlinePr writes the <outlinePr>, which goes in <sheetPr>.
+func encodeOutlinePr(w *bufio.Writer, h sheetHeader) {
+	if h.outline == nil {
+		return
+	}
+	w.WriteString(`<outlinePr`)
+	if h.outline.SummaryAbove {
+		w.WriteString(` summaryBelow="0"`)
+	}
+	if h.outline.SummaryLeft {
+		w.WriteString(` summaryRight="0"`)
+	}
+	w.WriteString(`/>`)
+}
+
+// encodeSheetFormatPr writes the <sheetFormatPr>, with the outline levels.
+func encodeSheetFormatPr(w *bufio.Writer, h sheetHeader) {
+	rowLevels := 0
+	if h.outline != nil {
+		rowLevels = h.outline.RowLevels
+	}
+	_, colLevels := colLevels(h.colGroups)
+	if rowLevels == 0 && colLevels == 0 {
+		return
+	}
+	w.WriteString(`<sheetFormatPr defaultRowHeight="15"`)
+	if rowLevels > 0 {
+		fmt.Fprintf(w, ` outlineLevelRow="%d"`, rowLevels)
+	}
+	if colLevels > 0 {
+		fmt.Fprintf(w, ` outlineLevelCol="%d"`, colLevels)
+	}
+	w.WriteString(`/>`)
+}
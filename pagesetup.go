@@ -0,0 +1,366 @@
+package streamxlsx
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxHeaderFooterLength is the longest header or footer text Excel accepts.
+const maxHeaderFooterLength = 255
+
+// Some paper sizes for PageSetup. Excel knows many more, see the
+// ST_PaperSize documentation for the full list.
+const (
+	PaperLetter = 1
+	PaperLegal  = 5
+	PaperA3     = 8
+	PaperA4     = 9
+	PaperA5     = 11
+)
+
+// PageSetup has the print settings of a sheet. See SetPageSetup().
+type PageSetup struct {
+	Landscape bool
+	PaperSize int // one of the Paper* constants. 0 is the printer's default
+	// Scale is the print zoom in percent, 10-400. 0 is 100%. It's ignored by
+	// Excel when FitToWidth or FitToHeight is set.
+	Scale int
+	// Fit the sheet to this many pages wide and/or tall. 0 is as many pages
+	// as needed. Fit to width is FitToWidth: 1, with FitToHeight 0.
+	FitToWidth, FitToHeight int
+	// Page margins. nil gives Excel's "normal" margins.
+	Margins *PageMargins
+	// Print the cell gridlines.
+	Gridlines bool
+	// Print the row and column headings ("A", "B", "1", "2", ...).
+	Headings bool
+	// Center the printed sheet on the page.
+	CenterHorizontally, CenterVertically bool
+	// Page number of the first page. 0 is automatic.
+	FirstPageNumber int
+	BlackAndWhite   bool
+	Draft           bool
+	// Header and footer text, printed on every page. These can have codes
+	// such as "&P" (page number), "&N" (number of pages), "&D" (date), "&A"
+	// (sheet name), and "&L", "&C", "&R" to start the left, center, or right
+	// part. For example: "&CPage &P of &N".
+	Header, Footer string
+	// Rows and columns to repeat on every printed page, such as "1:2" and
+	// "A:A".
+	TitleRows, TitleColumns string
+	// Only print this range, such as "A1:F20".
+	PrintArea string
+}
+
+// PageMargins are in inches.
+type PageMargins struct {
+	Left, Right, Top, Bottom float64
+	Header, Footer           float64 // distance from the page edge
+}
+
+// defaultMargins are the "normal" margins from Excel.
+var defaultMargins = PageMargins{
+	Left:   0.7,
+	Right:  0.7,
+	Top:    0.75,
+	Bottom: 0.75,
+	Header: 0.3,
+	Footer: 0.3,
+}
+
+func (p PageSetup) fitToPage() bool {
+	return p.FitToWidth > 0 || p.FitToHeight > 0
+}
+
+func (p PageSetup) validate() error {
+	if p.Scale != 0 && (p.Scale < 10 || p.Scale > 400) {
+		return fmt.Errorf("invalid print scale: %d", p.Scale)
+	}
+	if p.FitToWidth < 0 || p.FitToHeight < 0 {
+		return fmt.Errorf("invalid fit to page: %dx%d", p.FitToWidth, p.FitToHeight)
+	}
+	if p.PaperSize < 0 {
+		return fmt.Errorf("invalid paper size: %d", p.PaperSize)
+	}
+	if p.FirstPageNumber < 0 {
+		return fmt.Errorf("invalid first page number: %d", p.FirstPageNumber)
+	}
+	for _, hf := range []string{p.Header, p.Footer} {
+		if utf8.RuneCountInString(hf) > maxHeaderFooterLength {
+			return fmt.Errorf("header or footer longer than %d characters", maxHeaderFooterLength)
+		}
+	}
+	if _, err := p.names(); err != nil {
+		return err
+	}
+	if m := p.Margins; m != nil {
+		for _, v := range []float64{m.Left, m.Right, m.Top, m.Bottom, m.Header, m.Footer} {
+			if v < 0 {
+				return fmt.Errorf("invalid page margin: %v", v)
+			}
+		}
+	}
+	return nil
+}
+
+// names gives the print titles and print area, which are defined names in the
+// workbook. The refs still need the sheet name.
+func (p PageSetup) names() ([]definedName, error) {
+	var (
+		names  []definedName
+		titles []string
+	)
+	// Excel has the columns first
+	if p.TitleColumns != "" {
+		cols, err := absSpan(p.TitleColumns, func(c string) string { return c + "1" })
+		if err != nil {
+			return nil, err
+		}
+		titles = append(titles, cols)
+	}
+	if p.TitleRows != "" {
+		rows, err := absSpan(p.TitleRows, func(r string) string { return "A" + r })
+		if err != nil {
+			return nil, err
+		}
+		titles = append(titles, rows)
+	}
+	if len(titles) > 0 {
+		names = append(names, definedName{name: "_xlnm.Print_Titles", refs: titles})
+	}
+	if p.PrintArea != "" {
+		area, err := absRange(p.PrintArea)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, definedName{name: "_xlnm.Print_Area", refs: []string{area}})
+	}
+	return names, nil
+}
+
+// absSpan makes "$1:$2" from "1:2", and "$A:$B" from "A:B". The cell
+// function makes a parsable cell from either part.
+func absSpan(span string, cell func(string) string) (string, error) {
+	ps := strings.SplitN(span, ":", 2)
+	if len(ps) == 1 {
+		ps = append(ps, ps[0])
+	}
+	for i, p := range ps {
+		p = strings.ReplaceAll(p, "$", "")
+		if _, _, err := parseRef(cell(p)); err != nil {
+			return "", fmt.Errorf("invalid span: %q", span)
+		}
+		ps[i] = "$" + strings.ToUpper(p)
+	}
+	return ps[0] + ":" + ps[1], nil
+}
+
+// absRange makes "$A$1:$B$2" from "A1:B2".
+func absRange(ref string) (string, error) {
+	var ps []string
+	for _, r := range strings.SplitN(ref, ":", 2) {
+		col, row, err := parseRef(r)
+		if err != nil {
+			return "", err
+		}
+		ps = append(ps, "$"+asCol(col)+"$"+strconv.Itoa(row+1))
+	}
+	return strings.Join(ps, ":"), nil
+}
+
+// SetPageSetup sets the print settings of the current sheet. Since fitting to
+// a page is configured in the start of the sheet XML this needs to be called
+// before anything is written to the sheet. It applies until the next
+// WriteSheet(), so a rolled over sheet gets the same settings.
+func (s *StreamXLSX) SetPageSetup(p PageSetup) error {
+	if s.error != nil {
+		return s.error
+	}
+	if s.openSheet != nil {
+		s.error = ErrSheetStarted
+		return s.error
+	}
+	if err := p.validate(); err != nil {
+		s.error = err
+		return err
+	}
+	s.header.pageSetup = &p
+	return nil
+}
+
+// encodePageSetUpPr writes the <pageSetUpPr>, which goes in <sheetPr>.
+func encodePageSetUpPr(w *bufio.Writer, h sheetHeader) {
+	if h.pageSetup == nil || !h.pageSetup.fitToPage() {
+		return
+	}
+	w.WriteString(`<pageSetUpPr fitToPage="1"/>`)
+}
+
+// encodePageSetup writes the <printOptions>, <pageMargins>, and <pageSetup>.
+func encodePageSetup(w *bufio.Writer, p *PageSetup) {
+	if p == nil {
+		return
+	}
+
+	if p.Gridlines || p.Headings || p.CenterHorizontally || p.CenterVertically {
+		w.WriteString(`<printOptions`)
+		if p.CenterHorizontally {
+			w.WriteString(` horizontalCentered="1"`)
+		}
+		if p.CenterVertically {
+			w.WriteString(` verticalCentered="1"`)
+		}
+		if p.Headings {
+			w.WriteString(` headings="1"`)
+		}
+		if p.Gridlines {
+			w.WriteString(` gridLines="1"`)
+		}
+		w.WriteString(`/>`)
+	}
+
+	m := defaultMargins
+	if p.Margins != nil {
+		m = *p.Margins
+	}
+	w.WriteString(`<pageMargins`)
+	for _, a := range []struct {
+		key string
+		v   float64
+	}{
+		{"left", m.Left},
+		{"right", m.Right},
+		{"top", m.Top},
+		{"bottom", m.Bottom},
+		{"header", m.Header},
+		{"footer", m.Footer},
+	} {
+		writeAttr(w, a.key, strconv.FormatFloat(a.v, 'f', -1, 64))
+	}
+	w.WriteString(`/>`)
+
+	w.WriteString(`<pageSetup`)
+	if p.PaperSize > 0 {
+		fmt.Fprintf(w, ` paperSize="%d"`, p.PaperSize)
+	}
+	if p.Scale > 0 {
+		fmt.Fprintf(w, ` scale="%d"`, p.Scale)
+	}
+	if p.FirstPageNumber > 0 {
+		fmt.Fprintf(w, ` firstPageNumber="%d"`, p.FirstPageNumber)
+	}
+	if p.fitToPage() {
+		fmt.Fprintf(w, ` fitToWidth="%d" fitToHeight="%d"`, p.FitToWidth, p.FitToHeight)
+	}
+	if p.Landscape {
+		w.WriteString(` orientation="landscape"`)
+	} else {
+		w.WriteString(` orientation="portrait"`)
+	}
+	if p.FirstPageNumber > 0 {
+		w.WriteString(` useFirstPageNumber="1"`)
+	}
+	if p.BlackAndWhite {
+		w.WriteString(` blackAndWhite="1"`)
+	}
+	if p.Draft {
+		w.WriteString(` draft="1"`)
+	}
+	w.WriteString(`/>`)
+}
+
+// encodeHeaderFooter writes the <headerFooter>.
+func encodeHeaderFooter(w *bufio.Writer, p *PageSetup) {
+	if p == nil || (p.Header == "" && p.Footer == "") {
+		return
+	}
+	w.WriteString(`<headerFooter>`)
+	if p.Header != "" {
+		writeElem(w, "oddHeader", p.Header)
+	}
+	if p.Footer != "" {
+		writeElem(w, "oddFooter", p.Footer)
+	}
+	w.WriteString(`</headerFooter>`)
+}
+
+// AddRowBreak starts a new printed page at the (0-based) row of the current
+// sheet.
+func (s *StreamXLSX) AddRowBreak(row int) error {
+	if s.error != nil {
+		return s.error
+	}
+	sh, err := s.sheet()
+	if err != nil {
+		s.error = err
+		return err
+	}
+	if row < 1 || row >= MaxRows {
+		s.error = fmt.Errorf("invalid row break: %d", row)
+		return s.error
+	}
+	sh.rowBreaks = append(sh.rowBreaks, row)
+	return nil
+}
+
+// AddColumnBreak starts a new printed page at the (0-based) column of the
+// current sheet.
+func (s *StreamXLSX) AddColumnBreak(col int) error {
+	if s.error != nil {
+		return s.error
+	}
+	sh, err := s.sheet()
+	if err != nil {
+		s.error = err
+		return err
+	}
+	if col < 1 || col >= MaxColumns {
+		s.error = fmt.Errorf("invalid column break: %d", col)
+		return s.error
+	}
+	sh.colBreaks = append(sh.colBreaks, col)
+	return nil
+}
+
+// encodeBreaks writes <rowBreaks> or <colBreaks>. The breaks are before the
+// given 0-based row or column, max is the last column or row.
+func encodeBreaks(w *bufio.Writer, tag string, breaks []int, max int) {
+	if len(breaks) == 0 {
+		return
+	}
+	sort.Ints(breaks)
+	var ids []int
+	for i, b := range breaks {
+		if i == 0 || b != breaks[i-1] {
+			ids = append(ids, b)
+		}
+	}
+	fmt.Fprintf(w, `<%s count="%d" manualBreakCount="%d">`, tag, len(ids), len(ids))
+	for _, id := range ids {
+		fmt.Fprintf(w, `<brk id="%d" max="%d" man="1"/>`, id, max)
+	}
+	fmt.Fprintf(w, `</%s>`, tag)
+}
+
+// addPrintNames adds the print titles and print area of the open sheet, which
+// will be the next finished sheet.
+func (s *StreamXLSX) addPrintNames() error {
+	p := s.openSheet.header.pageSetup
+	if p == nil {
+		return nil
+	}
+	names, err := p.names()
+	if err != nil {
+		return err
+	}
+	for _, n := range names {
+		n.sheet = len(s.finishedSheets)
+		n.local = true
+		s.definedNames = append(s.definedNames, n)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package streamxlsx
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"strconv"
+	"unicode/utf16"
+)
+
+// passwordSpinCount is the number of hash iterations Excel uses.
+const passwordSpinCount = 100_000
+
+// SheetProtection protects a sheet against changes. See ProtectSheet(). By
+// default everything is protected, except selecting cells. Use
+// CellProtection() for cells which can be changed.
+type SheetProtection struct {
+	// Password is needed to unprotect the sheet. It's optional.
+	Password string
+	// Actions which are allowed in the protected sheet.
+	FormatCells, FormatColumns, FormatRows     bool
+	InsertColumns, InsertRows                  bool
+	InsertHyperlinks                           bool
+	DeleteColumns, DeleteRows                  bool
+	Sort, AutoFilter, PivotTables              bool
+	EditObjects, EditScenarios                 bool
+	NoSelectLockedCells, NoSelectUnlockedCells bool
+}
+
+// WorkbookProtection protects a workbook. See ProtectWorkbook().
+type WorkbookProtection struct {
+	Password string // optional
+	// Structure prevents adding, removing, renaming, hiding, or moving sheets.
+	Structure bool
+	// Windows prevents moving and resizing the workbook windows.
+	Windows bool
+}
+
+// passwordHash is a salted and hashed password.
+type passwordHash struct {
+	hash, salt string // base64
+}
+
+// ProtectSheet protects the current sheet. It's written when the sheet is
+// closed, so this can be called any time before WriteSheet(). Rolled over
+// sheets are also protected.
+func (s *StreamXLSX) ProtectSheet(p SheetProtection) error {
+	if s.error != nil {
+		return s.error
+	}
+	sh, err := s.sheet()
+	if err != nil {
+		s.error = err
+		return err
+	}
+	hash, err := hashPassword(p.Password)
+	if err != nil {
+		s.error = err
+		return err
+	}
+	sh.protection = &sheetProtection{p, hash}
+	return nil
+}
+
+// ProtectWorkbook protects the workbook.
+func (s *StreamXLSX) ProtectWorkbook(p WorkbookProtection) error {
+	if s.error != nil {
+		return s.error
+	}
+	hash, err := hashPassword(p.Password)
+	if err != nil {
+		s.error = err
+		return err
+	}
+	s.protection = &workbookProtectionXML{
+		LockStructure: b2i(p.Structure),
+		LockWindows:   b2i(p.Windows),
+	}
+	if hash != nil {
+		s.protection.AlgorithmName = "SHA-512"
+		s.protection.HashValue = hash.hash
+		s.protection.SaltValue = hash.salt
+		s.protection.SpinCount = passwordSpinCount
+	}
+	return nil
+}
+
+// CellProtection locks or unlocks a cell, and hides its formula. This only
+// has effect in a protected sheet. This is used to wrap a value in a
+// WriteRow(). To combine it with a number format, wrap the result of Format().
+func (s *StreamXLSX) CellProtection(locked, hidden bool, cell interface{}) Cell {
+	c, err := asCell(cell)
+	if err != nil {
+		s.error = err
+		return c
+	}
+	var xf Xf
+	if c.Style != nil && *c.Style < len(s.Styles.CellXfs) {
+		xf = s.Styles.CellXfs[*c.Style]
+	} else {
+		cellStyleID := s.Styles.GetCellStyleID(Xf{})
+		xf.XfID = &cellStyleID
+	}
+	xf.ApplyProtection = 1
+	xf.Protection = &Protection{Locked: b2i(locked), Hidden: b2i(hidden)}
+	id := s.Styles.GetCellID(xf)
+	c.Style = &id
+	return c
+}
+
+// hashPassword hashes a password the way Excel does: SHA-512 of a random salt
+// and the UTF-16 password, followed by passwordSpinCount rounds of hashing the
+// hash with the round number. No password gives nil.
+func hashPassword(password string) (*passwordHash, error) {
+	if password == "" {
+		return nil, nil
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return &passwordHash{
+		hash: base64.StdEncoding.EncodeToString(hashPasswordSalt(password, salt, passwordSpinCount)),
+		salt: base64.StdEncoding.EncodeToString(salt),
+	}, nil
+}
+
+func hashPasswordSalt(password string, salt []byte, spinCount int) []byte {
+	pw := utf16.Encode([]rune(password))
+	b := make([]byte, len(salt)+2*len(pw))
+	copy(b, salt)
+	for i, u := range pw {
+		binary.LittleEndian.PutUint16(b[len(salt)+2*i:], u)
+	}
+	h := sha512.Sum512(b)
+	buf := make([]byte, len(h)+4)
+	for i := 0; i < spinCount; i++ {
+		copy(buf, h[:])
+		binary.LittleEndian.PutUint32(buf[len(h):], uint32(i))
+		h = sha512.Sum512(buf)
+	}
+	return h[:]
+}
+
+type sheetProtection struct {
+	SheetProtection
+	hash *passwordHash
+}
+
+// encodeSheetProtection writes the <sheetProtection>. Most attributes are
+// "is protected", not "is allowed".
+func encodeSheetProtection(w *bufio.Writer, p *sheetProtection) {
+	if p == nil {
+		return
+	}
+	w.WriteString(`<sheetProtection`)
+	if p.hash != nil {
+		writeAttr(w, "algorithmName", "SHA-512")
+		writeAttr(w, "hashValue", p.hash.hash)
+		writeAttr(w, "saltValue", p.hash.salt)
+		writeAttr(w, "spinCount", strconv.Itoa(passwordSpinCount))
+	}
+	w.WriteString(` sheet="1"`)
+	for _, a := range []struct {
+		attr string
+		on   bool
+	}{
+		{"objects", !p.EditObjects},
+		{"scenarios", !p.EditScenarios},
+		{"formatCells", !p.FormatCells},
+		{"formatColumns", !p.FormatColumns},
+		{"formatRows", !p.FormatRows},
+		{"insertColumns", !p.InsertColumns},
+		{"insertRows", !p.InsertRows},
+		{"insertHyperlinks", !p.InsertHyperlinks},
+		{"deleteColumns", !p.DeleteColumns},
+		{"deleteRows", !p.DeleteRows},
+		{"selectLockedCells", p.NoSelectLockedCells},
+		{"sort", !p.Sort},
+		{"autoFilter", !p.AutoFilter},
+		{"pivotTables", !p.PivotTables},
+		{"selectUnlockedCells", p.NoSelectUnlockedCells},
+	} {
+		if a.on {
+			writeAttr(w, a.attr, "1")
+		} else {
+			writeAttr(w, a.attr, "0")
+		}
+	}
+	w.WriteString(`/>`)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"strconv"
 )
 
 // TestFile is used to test the package.
@@ -43,8 +44,13 @@ func TestParse(b []byte) (*TestFile, error) {
 		return nil, err
 	}
 
-	for i, sheet := range workbook.Sheets {
-		s, err := readSheet(z, i+1, sheet.Name)
+	for _, sheet := range workbook.Sheets {
+		// the sheet ID is the file number
+		id, err := strconv.Atoi(sheet.ID)
+		if err != nil {
+			return nil, err
+		}
+		s, err := readSheet(z, id, sheet.Name)
 		if err != nil {
 			return nil, err
 		}
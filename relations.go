@@ -19,14 +19,16 @@ type relationship struct {
 	TargetMode string `xml:"TargetMode,attr,omitempty"`
 }
 
-func writeRelations(fh io.Writer) error {
-	return writeRelations_(fh, []relationship{
+// writeRelations writes the package relationships: the workbook, and the extra
+// ones, such as the document properties.
+func writeRelations(fh io.Writer, extra []relationship) error {
+	return writeRelations_(fh, append([]relationship{
 		{
 			ID:     "rId1",
 			Target: "/xl/workbook.xml",
 			Type:   "http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument",
 		},
-	})
+	}, extra...))
 }
 
 func writeWorkbookRelations(fh io.Writer, sheetTitles []string) error {
@@ -61,3 +63,25 @@ func writeRelations_(fh io.Writer, rels []relationship) error {
 		Relationships: rels,
 	})
 }
+
+// writeSheetRelations writes the relationships of a sheet, including the ones
+// of its hyperlinks.
+func writeSheetRelations(fh io.Writer, rels []relationship, links *linkBuffer) error {
+	fh.Write([]byte(xml.Header))
+	io.WriteString(fh, `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for _, rel := range rels {
+		if err := encodeRelationship(fh, rel); err != nil {
+			return err
+		}
+	}
+	if err := links.encodeRelations(fh); err != nil {
+		return err
+	}
+	_, err := io.WriteString(fh, `</Relationships>`)
+	return err
+}
+
+// encodeRelationship writes a single <Relationship>.
+func encodeRelationship(w io.Writer, rel relationship) error {
+	return xml.NewEncoder(w).EncodeElement(rel, xml.StartElement{Name: xml.Name{Local: "Relationship"}})
+}
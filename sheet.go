@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 )
 
 type worksheetXML struct {
@@ -19,115 +20,216 @@ type rowXML struct {
 	Index int    `xml:"r,attr"`
 }
 
-type hyperlink struct {
-	RelID   string `xml:"r:id,attr"`
-	Ref     string `xml:"ref,attr"`
-	Display string `xml:"display,attr"`
-	Tooltip string `xml:"tooltip,attr"`
-	url     string
+// RowOptions are used with WriteRowWithOptions(). The zero value is a plain
+// row.
+type RowOptions struct {
+	Height       float64 // in points. 0 is the default height
+	Hidden       bool
+	OutlineLevel int    // 0-7
+	Collapsed    bool   // for outlined rows
+	Format       string // number format for empty cells in the row. See Format()
+	style        int    // CellXf ID for Format
 }
 
 type sheetEncoder struct {
-	buf        *bufio.Writer
-	rows       int
-	hyperlinks []hyperlink
-	relations  []relationship
+	id           int // 1-based sheet number
+	buf          *bufio.Writer
+	rows         int
+	cols         int // widest row
+	longStrings  LongStringPolicy
+	header       sheetHeader
+	rowGroups    []rowGroup
+	collapseNext bool // mark the next row as collapsed
+	links        linkBuffer
+	comments     []comment
+	validations  []DataValidation
+	conditionals []conditionalFormatting
+	cfPriority   int
+	openTable    *table
+	tables       []table
+	drawing      *drawing
+	sparklines   []SparklineGroup
+	names        []DefinedName // added when the sheet is closed
+	protection   *sheetProtection
+	rowBreaks    []int
+	colBreaks    []int
+	relations    []relationship // other than for links
 }
 
-func newSheetEncoder(fh io.Writer) (*sheetEncoder, error) {
+func newSheetEncoder(fh io.Writer, id int, header sheetHeader) (*sheetEncoder, error) {
 	fh.Write([]byte(xml.Header))
 
 	sh := &sheetEncoder{
-		buf: bufio.NewWriterSize(fh, 1_000_000),
+		id:     id,
+		buf:    bufio.NewWriterSize(fh, 1_000_000),
+		header: header,
 	}
 
-	return sh, sheetOpen(sh.buf)
+	return sh, sheetOpen(sh.buf, header)
 }
 
-func (sh *sheetEncoder) Close() {
-	sheetClose(sh.buf, sh.hyperlinks)
-	sh.buf.Flush()
+func (sh *sheetEncoder) Close() error {
+	if err := sheetClose(sh.buf, sh); err != nil {
+		return err
+	}
+	return sh.buf.Flush()
 }
 
 func (sh *sheetEncoder) writeRow(cs ...interface{}) error {
-	fmt.Fprintf(sh.buf, `<row r="%d">`, sh.rows+1)
-	for i, v := range cs {
+	return sh.writeRowAt(sh.rows, 0, RowOptions{}, cs...)
+}
+
+// writeRowAt writes a row at a 0-based position. Row numbers need to go up,
+// skipped rows are simply not written.
+func (sh *sheetEncoder) writeRowAt(row, col int, opts RowOptions, cs ...interface{}) error {
+	if row < sh.rows {
+		return ErrRowOrder
+	}
+	if col < 0 {
+		return fmt.Errorf("invalid column: %d", col)
+	}
+	if row >= maxRows {
+		return ErrTooManyRows
+	}
+	if col+len(cs) > MaxColumns {
+		return ErrTooManyColumns
+	}
+	opts = sh.applyRowGroups(opts)
+	if opts.OutlineLevel < 0 || opts.OutlineLevel > 7 {
+		return fmt.Errorf("invalid outline level: %d", opts.OutlineLevel)
+	}
+
+	writeRowOpen(sh.buf, row, opts)
+	for _, v := range cs {
 		if v == nil {
+			col++
 			continue
 		}
 		cell, err := asCell(v)
 		if err != nil {
 			return err
 		}
-		cell.Ref = AsRef(i, sh.rows)
-		writeCell(sh.buf, cell)
-
-		// hyperlinks refs are written at the end of the sheet
-		if link := cell.hyperlink; link != nil {
-			linkID := sh.addLinkRelation(link.url)
-			sh.hyperlinks = append(sh.hyperlinks, hyperlink{
-				RelID:   linkID,
-				Ref:     cell.Ref,
-				Display: link.Display,
-				Tooltip: link.Tooltip,
-			})
+		// long strings might be split over more cells
+		cells, err := limitLength(sh.longStrings, AsRef(col, row), cell)
+		if err != nil {
+			return err
+		}
+		for _, cell := range cells {
+			if col >= MaxColumns {
+				return ErrTooManyColumns
+			}
+			cell.Ref = AsRef(col, row)
+			writeCell(sh.buf, cell)
+
+			// comments are written when the sheet is closed
+			if c := cell.comment; c != nil {
+				sh.addComment(comment{
+					ref:    cell.Ref,
+					row:    row,
+					col:    col,
+					author: c.Author,
+					text:   c.runs(),
+				})
+			}
+			col++
+
+			// hyperlinks refs are written at the end of the sheet
+			if link := cell.hyperlink; link != nil {
+				l := hyperlink{
+					Ref:     cell.Ref,
+					Display: link.Display,
+					Tooltip: link.Tooltip,
+				}
+				url := link.url
+				if strings.HasPrefix(url, "#") {
+					// internal links don't need a relationship
+					l.Location, url = url[1:], ""
+				}
+				if err := sh.links.add(l, url); err != nil {
+					return err
+				}
+			}
 		}
 	}
 	sh.buf.WriteString(`</row>`)
 
-	sh.rows++
+	sh.rows = row + 1
+	if col > sh.cols {
+		sh.cols = col
+	}
 
 	return nil
 }
 
-func (sh *sheetEncoder) addLinkRelation(url string) string {
-	id := fmt.Sprintf("linkId%d", len(sh.relations)+1)
-	sh.relations = append(sh.relations, relationship{
-		ID:         id,
-		Type:       "http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink",
-		Target:     url,
-		TargetMode: "External",
-	})
-	return id
+func writeRowOpen(w *bufio.Writer, row int, opts RowOptions) {
+	fmt.Fprintf(w, `<row r="%d"`, row+1)
+	if opts.Height > 0 {
+		w.WriteString(` ht="`)
+		w.WriteString(strconv.FormatFloat(opts.Height, 'f', -1, 64))
+		w.WriteString(`" customHeight="1"`)
+	}
+	if opts.Hidden {
+		w.WriteString(` hidden="1"`)
+	}
+	if opts.OutlineLevel > 0 {
+		fmt.Fprintf(w, ` outlineLevel="%d"`, opts.OutlineLevel)
+	}
+	if opts.Collapsed {
+		w.WriteString(` collapsed="1"`)
+	}
+	if opts.style != 0 {
+		fmt.Fprintf(w, ` s="%d" customFormat="1"`, opts.style)
+	}
+	w.WriteString(`>`)
 }
 
-func sheetOpen(w *bufio.Writer) error {
+func sheetOpen(w *bufio.Writer, h sheetHeader) error {
 	w.WriteString(`<worksheet
 xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"
 xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"
-><sheetData>`)
+>`)
+	encodeSheetPr(w, h)
+	encodeSheetViews(w, h)
+	encodeSheetFormatPr(w, h)
+	encodeCols(w, h)
+	w.WriteString(`<sheetData>`)
 	return nil
 }
 
-func sheetClose(w *bufio.Writer, links []hyperlink) error {
+// encodeSheetPr writes the <sheetPr>, if there are any sheet properties.
+func encodeSheetPr(w *bufio.Writer, h sheetHeader) {
+	tabColor := h.view != nil && h.view.TabColor != ""
+	if !tabColor && h.outline == nil && (h.pageSetup == nil || !h.pageSetup.fitToPage()) {
+		return
+	}
+	w.WriteString(`<sheetPr>`)
+	encodeTabColor(w, h)
+	encodeOutlinePr(w, h)
+	encodePageSetUpPr(w, h)
+	w.WriteString(`</sheetPr>`)
+}
+
+func sheetClose(w *bufio.Writer, sh *sheetEncoder) error {
 	w.WriteString(`</sheetData>`)
-	if err := encodeHyperlinks(w, links); err != nil {
+	// the order of these is fixed by the schema
+	encodeSheetProtection(w, sh.protection)
+	encodeConditionalFormatting(w, sh.conditionals)
+	encodeDataValidations(w, sh.validations)
+	if err := sh.links.encode(w); err != nil {
 		return err
 	}
+	encodePageSetup(w, sh.header.pageSetup)
+	encodeHeaderFooter(w, sh.header.pageSetup)
+	encodeBreaks(w, "rowBreaks", sh.rowBreaks, MaxColumns-1)
+	encodeBreaks(w, "colBreaks", sh.colBreaks, MaxRows-1)
+	encodeDrawing(w, sh.drawing)
+	encodeLegacyDrawing(w, sh.comments)
+	encodeTableParts(w, sh.tables)
+	encodeExtLst(w, sh.sparklines)
 	w.WriteString(`</worksheet>`)
 	return nil
 }
 
-func encodeHyperlinks(w *bufio.Writer, links []hyperlink) error {
-	if len(links) == 0 {
-		return nil
-	}
-	w.WriteString(`<hyperlinks>`)
-	for _, link := range links {
-		w.WriteString(`<hyperlink r:id="`)
-		xml.EscapeText(w, []byte(link.RelID))
-		w.WriteString(`" ref="`)
-		xml.EscapeText(w, []byte(link.Ref))
-		w.WriteString(`" display="`)
-		xml.EscapeText(w, []byte(link.Display))
-		w.WriteString(`" tooltip="`)
-		xml.EscapeText(w, []byte(link.Tooltip))
-		w.WriteString(`"/>`)
-	}
-	w.WriteString(`</hyperlinks>`)
-	return nil
-}
-
 // AsRef makes an 'A13' style ref. Arguments are 0-based.
 func AsRef(column, row int) string {
 	return asCol(column) + strconv.Itoa(row+1)
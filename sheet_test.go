@@ -1,6 +1,7 @@
 package streamxlsx
 
 import (
+	"encoding/base64"
 	"testing"
 )
 
@@ -28,6 +29,53 @@ func TestAsRef(t *testing.T) {
 	mustEq(t, "ABA1", AsRef(27*26+26, 0))
 }
 
+func TestParseRef(t *testing.T) {
+	for ref, want := range map[string][2]int{
+		"A1":       {0, 0},
+		"b2":       {1, 1},
+		"$AA$10":   {26, 9},
+		"XFD1":     {MaxColumns - 1, 0},
+		"A1048576": {0, MaxRows - 1},
+	} {
+		col, row, err := parseRef(ref)
+		if err != nil {
+			t.Fatalf("%q: %s", ref, err)
+		}
+		if col != want[0] || row != want[1] {
+			t.Errorf("%q: have %d,%d, want %v", ref, col, row, want)
+		}
+	}
+	for _, ref := range []string{"", "A", "1", "A0", "1A", "XFE1", "A1048577", "A1:B2"} {
+		if _, _, err := parseRef(ref); err == nil {
+			t.Errorf("%q: expected an error", ref)
+		}
+	}
+}
+
+func TestValidateDefinedName(t *testing.T) {
+	for _, n := range []string{"TaxRate", "_x", "Prices.2020", "ABCD1", "Rate", "\\path"} {
+		if err := validateDefinedName(n); err != nil {
+			t.Errorf("%q: %s", n, err)
+		}
+	}
+	for _, n := range []string{"", "A1", "xfd100", "R1C1", "RC", "r", "1abc", "with space", "a-b"} {
+		if err := validateDefinedName(n); err == nil {
+			t.Errorf("%q: expected an error", n)
+		}
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	salt := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	hash := hashPasswordSalt("secret", salt, passwordSpinCount)
+	mustEq(t, "M5SOVnbQG4SHyBnRVAYzAx8mPtxyyzMuWxcMv7tkyFO3MBXX9OJjklwPglNHdoHVkKPm4MPfUblqHmAsXfF5HA==", base64.StdEncoding.EncodeToString(hash))
+
+	h, err := hashPassword("")
+	if err != nil || h != nil {
+		t.Fatalf("have %v, %v, want no hash", h, err)
+	}
+}
+
 func mustEq(t *testing.T, want, have string) {
 	t.Helper()
 	if have != want {
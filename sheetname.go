@@ -0,0 +1,85 @@
+package streamxlsx
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxSheetNameLength is the longest sheet title Excel accepts.
+const MaxSheetNameLength = 31
+
+// forbidden characters in sheet titles
+const sheetNameForbidden = `:\/?*[]`
+
+// SheetNameError is returned by WriteSheet() for a title Excel won't accept.
+// See StreamXLSX.SanitizeSheetNames to fix titles automatically.
+type SheetNameError struct {
+	Name   string
+	Reason string
+}
+
+func (e *SheetNameError) Error() string {
+	return fmt.Sprintf("invalid sheet name %q: %s", e.Name, e.Reason)
+}
+
+// validateSheetName checks a title against the rules of Excel. taken are the
+// titles already in use.
+func validateSheetName(name string, taken []string) error {
+	switch {
+	case name == "":
+		return &SheetNameError{name, "empty"}
+	case utf8.RuneCountInString(name) > MaxSheetNameLength:
+		return &SheetNameError{name, fmt.Sprintf("longer than %d characters", MaxSheetNameLength)}
+	case strings.ContainsAny(name, sheetNameForbidden):
+		return &SheetNameError{name, fmt.Sprintf("contains one of %s", sheetNameForbidden)}
+	case strings.HasPrefix(name, "'") || strings.HasSuffix(name, "'"):
+		return &SheetNameError{name, "starts or ends with an apostrophe"}
+	case strings.EqualFold(name, "History"):
+		return &SheetNameError{name, "reserved name"}
+	case sheetNameTaken(name, taken):
+		return &SheetNameError{name, "duplicate name"}
+	}
+	return nil
+}
+
+// sanitizeSheetName changes a title until validateSheetName() is happy with it.
+func sanitizeSheetName(name string, taken []string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(sheetNameForbidden, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(truncateRunes(strings.Trim(name, "'"), MaxSheetNameLength), "'")
+	if name == "" {
+		name = "sheet"
+	}
+
+	base := name
+	for n := 2; strings.EqualFold(name, "History") || sheetNameTaken(name, taken); n++ {
+		suffix := fmt.Sprintf(" (%d)", n)
+		name = truncateRunes(base, MaxSheetNameLength-len(suffix)) + suffix
+	}
+	return name
+}
+
+// case insensitive
+func sheetNameTaken(name string, taken []string) bool {
+	for _, t := range taken {
+		if strings.EqualFold(name, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncateRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
@@ -0,0 +1,31 @@
+package streamxlsx
+
+import (
+	"fmt"
+)
+
+// sheetOrder gives the 0-based indexes of the finished sheets in the order of
+// the tabs. See StreamXLSX.SheetOrder.
+func (s *StreamXLSX) sheetOrder() ([]int, error) {
+	var (
+		order = make([]int, 0, len(s.finishedSheets))
+		seen  = map[int]bool{}
+	)
+	for _, title := range s.SheetOrder {
+		i, err := s.sheetIndex(title)
+		if err != nil {
+			return nil, err
+		}
+		if seen[i] {
+			return nil, fmt.Errorf("duplicate sheet in order: %q", title)
+		}
+		seen[i] = true
+		order = append(order, i)
+	}
+	for i := range s.finishedSheets {
+		if !seen[i] {
+			order = append(order, i)
+		}
+	}
+	return order, nil
+}
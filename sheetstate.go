@@ -0,0 +1,82 @@
+package streamxlsx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sheet states, for SetSheetState().
+const (
+	SheetVisible = "visible"
+	// Hidden sheets can be unhidden by the user.
+	SheetHidden = "hidden"
+	// Very hidden sheets can only be unhidden with VBA.
+	SheetVeryHidden = "veryHidden"
+)
+
+// ErrNoVisibleSheet is returned by Close() when all sheets are hidden.
+var ErrNoVisibleSheet = errors.New("at least one sheet must be visible")
+
+// SetSheetState hides (or unhides) the current sheet. Hidden sheets can still
+// be used in formulas and data validations. It applies until the next
+// WriteSheet(), so rolled over sheets get the same state.
+func (s *StreamXLSX) SetSheetState(state string) error {
+	if s.error != nil {
+		return s.error
+	}
+	switch state {
+	case SheetVisible, SheetHidden, SheetVeryHidden:
+	default:
+		s.error = fmt.Errorf("invalid sheet state: %q", state)
+		return s.error
+	}
+	s.sheetState = state
+	return nil
+}
+
+// bookView gives the active sheet and the first visible tab, as 0-based sheet
+// indexes. Order is the order of the tabs.
+func (s *StreamXLSX) bookView(order []int) (int, int, error) {
+	active := -1
+	for _, i := range order {
+		if state := s.sheetStates[i]; state == "" || state == SheetVisible {
+			active = i
+			break
+		}
+	}
+	if active < 0 {
+		return 0, 0, ErrNoVisibleSheet
+	}
+
+	if s.ActiveSheet != "" {
+		i, err := s.sheetIndex(s.ActiveSheet)
+		if err != nil {
+			return 0, 0, err
+		}
+		if st := s.sheetStates[i]; st != "" && st != SheetVisible {
+			return 0, 0, fmt.Errorf("active sheet %q is hidden", s.ActiveSheet)
+		}
+		active = i
+	}
+
+	first := order[0]
+	if s.FirstVisibleTab != "" {
+		i, err := s.sheetIndex(s.FirstVisibleTab)
+		if err != nil {
+			return 0, 0, err
+		}
+		first = i
+	}
+	return active, first, nil
+}
+
+// sheetIndex finds a finished sheet by title. Titles are case insensitive.
+func (s *StreamXLSX) sheetIndex(title string) (int, error) {
+	for i, t := range s.finishedSheets {
+		if strings.EqualFold(t, title) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no such sheet: %q", title)
+}
@@ -0,0 +1,100 @@
+package streamxlsx
+
+import (
+	"bufio"
+	"fmt"
+)
+
+// SheetView has the display settings of a sheet. See SetSheetView().
+type SheetView struct {
+	Zoom          int // in percent, 10-400. 0 is 100%
+	HideGridlines bool
+	// Hide the row and column headings ("A", "B", "1", "2", ...).
+	HideHeadings bool
+	RightToLeft  bool
+	ShowFormulas bool
+	// Selected is the initially selected cell, such as "B2".
+	Selected string
+	TabColor string // "RRGGBB"
+}
+
+func (v SheetView) validate() error {
+	if v.Zoom != 0 && (v.Zoom < 10 || v.Zoom > 400) {
+		return fmt.Errorf("invalid zoom: %d", v.Zoom)
+	}
+	if v.Selected != "" {
+		if _, _, err := parseRef(v.Selected); err != nil {
+			return err
+		}
+	}
+	if v.TabColor != "" {
+		if err := validateColor(v.TabColor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetSheetView sets the display settings of the current sheet. This needs to
+// be called before anything is written to the sheet, and applies until the
+// next WriteSheet().
+func (s *StreamXLSX) SetSheetView(v SheetView) error {
+	if s.error != nil {
+		return s.error
+	}
+	if s.openSheet != nil {
+		s.error = ErrSheetStarted
+		return s.error
+	}
+	if err := v.validate(); err != nil {
+		s.error = err
+		return err
+	}
+	s.header.view = &v
+	return nil
+}
+
+// encodeTabColor writes the <tabColor>, which goes in <sheetPr>.
+func encodeTabColor(w *bufio.Writer, h sheetHeader) {
+	if h.view == nil || h.view.TabColor == "" {
+		return
+	}
+	w.WriteString(`<tabColor`)
+	writeAttr(w, "rgb", argb(h.view.TabColor))
+	w.WriteString(`/>`)
+}
+
+// encodeSheetViews writes the <sheetViews>.
+func encodeSheetViews(w *bufio.Writer, h sheetHeader) {
+	v := h.view
+	if v == nil {
+		return
+	}
+	w.WriteString(`<sheetViews><sheetView`)
+	if v.ShowFormulas {
+		w.WriteString(` showFormulas="1"`)
+	}
+	if v.HideGridlines {
+		w.WriteString(` showGridLines="0"`)
+	}
+	if v.HideHeadings {
+		w.WriteString(` showRowColHeaders="0"`)
+	}
+	if v.RightToLeft {
+		w.WriteString(` rightToLeft="1"`)
+	}
+	if v.Zoom > 0 {
+		fmt.Fprintf(w, ` zoomScale="%d" zoomScaleNormal="%d"`, v.Zoom, v.Zoom)
+	}
+	w.WriteString(` workbookViewId="0"`)
+	if v.Selected == "" {
+		w.WriteString(`/></sheetViews>`)
+		return
+	}
+	col, row, _ := parseRef(v.Selected)
+	ref := AsRef(col, row)
+	w.WriteString(`><selection`)
+	writeAttr(w, "activeCell", ref)
+	writeAttr(w, "sqref", ref)
+	w.WriteString(`/></sheetView></sheetViews>`)
+}
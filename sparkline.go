@@ -0,0 +1,184 @@
+package streamxlsx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Types of sparklines.
+const (
+	SparklineLine    = "line"
+	SparklineColumn  = "column"
+	SparklineWinLoss = "winloss"
+)
+
+// SparklineGroup is a set of sparklines which share their options. See
+// AddSparklines(). Colors are "RRGGBB", and have Excel's defaults.
+type SparklineGroup struct {
+	Type       string // one of the Sparkline* constants. Defaults to line.
+	Sparklines []Sparkline
+	// Highlight points.
+	Markers, High, Low, First, Last, Negative     bool
+	Color, NegativeColor, MarkersColor, AxisColor string
+	HighColor, LowColor, FirstColor, LastColor    string
+	// ShowAxis draws the horizontal axis.
+	ShowAxis bool
+	// SameAxis uses the same min and max for all sparklines in the group.
+	SameAxis bool
+	// Fixed min and/or max for the vertical axis.
+	AxisMin, AxisMax *float64
+}
+
+// Sparkline is a single sparkline in cell Ref, with the data in range Data.
+// Excel needs the sheet name in Data, also for data on the same sheet. See
+// SheetRef().
+type Sparkline struct {
+	Ref  string // "F2"
+	Data string // "'Sales'!A2:E2"
+}
+
+// AddSparklines adds a group of sparklines to the current sheet. They are
+// written when the sheet is closed, so this can be called any time before
+// WriteSheet().
+func (s *StreamXLSX) AddSparklines(g SparklineGroup) error {
+	if s.error != nil {
+		return s.error
+	}
+	sh, err := s.sheet()
+	if err != nil {
+		s.error = err
+		return err
+	}
+	if err := g.validate(); err != nil {
+		s.error = err
+		return err
+	}
+	sh.sparklines = append(sh.sparklines, g)
+	return nil
+}
+
+func (g SparklineGroup) validate() error {
+	switch g.Type {
+	case "", SparklineLine, SparklineColumn, SparklineWinLoss:
+	default:
+		return fmt.Errorf("invalid sparkline type: %q", g.Type)
+	}
+	if len(g.Sparklines) == 0 {
+		return errors.New("sparkline group without sparklines")
+	}
+	for _, sp := range g.Sparklines {
+		if _, _, err := parseRef(sp.Ref); err != nil {
+			return err
+		}
+		if sp.Data == "" {
+			return errors.New("sparkline without data")
+		}
+		if !strings.Contains(sp.Data, "!") {
+			return fmt.Errorf("sparkline data without a sheet name: %q", sp.Data)
+		}
+	}
+	for _, c := range []string{
+		g.Color, g.NegativeColor, g.MarkersColor, g.AxisColor,
+		g.HighColor, g.LowColor, g.FirstColor, g.LastColor,
+	} {
+		if c == "" {
+			continue
+		}
+		if err := validateColor(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeExtLst writes the extensions, which are only the sparklines.
+func encodeExtLst(w *bufio.Writer, groups []SparklineGroup) {
+	if len(groups) == 0 {
+		return
+	}
+	w.WriteString(`<extLst><ext uri="{05C60535-1F16-4fd2-B633-F4F36F0B64E0}" xmlns:x14="http://schemas.microsoft.com/office/spreadsheetml/2009/9/main">`)
+	w.WriteString(`<x14:sparklineGroups xmlns:xm="http://schemas.microsoft.com/office/excel/2006/main">`)
+	for _, g := range groups {
+		encodeSparklineGroup(w, g)
+	}
+	w.WriteString(`</x14:sparklineGroups></ext></extLst>`)
+}
+
+func encodeSparklineGroup(w *bufio.Writer, g SparklineGroup) {
+	w.WriteString(`<x14:sparklineGroup`)
+	if g.AxisMax != nil {
+		writeAttr(w, "manualMax", strconv.FormatFloat(*g.AxisMax, 'f', -1, 64))
+	}
+	if g.AxisMin != nil {
+		writeAttr(w, "manualMin", strconv.FormatFloat(*g.AxisMin, 'f', -1, 64))
+	}
+	switch g.Type {
+	case SparklineColumn:
+		w.WriteString(` type="column"`)
+	case SparklineWinLoss:
+		w.WriteString(` type="stacked"`)
+	}
+	w.WriteString(` displayEmptyCellsAs="gap"`)
+	for _, b := range []struct {
+		attr string
+		on   bool
+	}{
+		{"markers", g.Markers},
+		{"high", g.High},
+		{"low", g.Low},
+		{"first", g.First},
+		{"last", g.Last},
+		{"negative", g.Negative},
+		{"displayXAxis", g.ShowAxis},
+	} {
+		if b.on {
+			fmt.Fprintf(w, ` %s="1"`, b.attr)
+		}
+	}
+	switch {
+	case g.AxisMin != nil:
+		w.WriteString(` minAxisType="custom"`)
+	case g.SameAxis:
+		w.WriteString(` minAxisType="group"`)
+	}
+	switch {
+	case g.AxisMax != nil:
+		w.WriteString(` maxAxisType="custom"`)
+	case g.SameAxis:
+		w.WriteString(` maxAxisType="group"`)
+	}
+	w.WriteString(`>`)
+
+	for _, c := range []struct {
+		tag, color, def string
+	}{
+		{"colorSeries", g.Color, "376092"},
+		{"colorNegative", g.NegativeColor, "D00000"},
+		{"colorAxis", g.AxisColor, "000000"},
+		{"colorMarkers", g.MarkersColor, "D00000"},
+		{"colorFirst", g.FirstColor, "D00000"},
+		{"colorLast", g.LastColor, "D00000"},
+		{"colorHigh", g.HighColor, "D00000"},
+		{"colorLow", g.LowColor, "D00000"},
+	} {
+		color := c.color
+		if color == "" {
+			color = c.def
+		}
+		fmt.Fprintf(w, `<x14:%s`, c.tag)
+		writeAttr(w, "rgb", argb(color))
+		w.WriteString(`/>`)
+	}
+
+	w.WriteString(`<x14:sparklines>`)
+	for _, sp := range g.Sparklines {
+		w.WriteString(`<x14:sparkline>`)
+		writeElem(w, "xm:f", sp.Data)
+		writeElem(w, "xm:sqref", sp.Ref)
+		w.WriteString(`</x14:sparkline>`)
+	}
+	w.WriteString(`</x14:sparklines></x14:sparklineGroup>`)
+}
@@ -5,8 +5,32 @@ package streamxlsx
 
 import (
 	"archive/zip"
+	"errors"
 	"fmt"
 	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Limits of an .xlsx file, as enforced by Excel.
+const (
+	MaxRows    = 1_048_576
+	MaxColumns = 16_384 // "XFD"
+)
+
+// maxRows is MaxRows, but lower in tests.
+var maxRows = MaxRows
+
+var (
+	// ErrTooManyRows is returned when a sheet would get more than MaxRows
+	// rows. See StreamXLSX.Rollover to continue on a new sheet instead.
+	ErrTooManyRows = errors.New("too many rows in sheet")
+	// ErrTooManyColumns is returned when a row would get more than MaxColumns
+	// cells.
+	ErrTooManyColumns = errors.New("too many columns in row")
+	// ErrRowOrder is returned by WriteRowAt() for a row which is not after
+	// the previously written row.
+	ErrRowOrder = errors.New("rows must be written in increasing order")
 )
 
 type StreamXLSX struct {
@@ -15,9 +39,56 @@ type StreamXLSX struct {
 	finishedSheets []string
 	// The stylesheet will be written on Close(). You generally won't want to
 	// use this directly, but via `Format()`.
-	Styles     *Stylesheet
-	styleCache map[string]int
-	error      error // returned with Close()
+	Styles *Stylesheet
+	// With Rollover set a full sheet (see MaxRows) is closed and writing
+	// continues on a new sheet, instead of WriteRow() returning
+	// ErrTooManyRows. The continuation sheets get the title passed to
+	// WriteSheet() with " (2)", " (3)", &c. appended, shortened if needed.
+	Rollover bool
+	// RolloverHeader is written as the first row of every continuation sheet.
+	// Only used with Rollover.
+	RolloverHeader []interface{}
+	// SanitizeSheetNames makes WriteSheet() fix titles Excel won't accept,
+	// instead of returning a *SheetNameError. Forbidden characters are
+	// replaced, long titles are truncated, and duplicates get a " (2)" &c.
+	// suffix.
+	SanitizeSheetNames bool
+	// LongStrings decides what WriteRow() does with strings longer than
+	// MaxCellLength. The default is to return an error.
+	LongStrings LongStringPolicy
+	// SpillHyperlinks buffers the hyperlinks of a sheet in temp files, instead
+	// of in memory, until the sheet is closed. Use this for sheets with very
+	// many links.
+	SpillHyperlinks bool
+	// ActiveSheet is the title of the sheet which is shown when the file is
+	// opened. Default is the first visible sheet.
+	ActiveSheet string
+	// SheetOrder has sheet titles in the order their tabs should have, which
+	// doesn't have to be the order they were written. For example, to show
+	// a summary sheet which is written last as the first tab. Sheets not in
+	// the list follow in the order they were written.
+	SheetOrder []string
+	// FirstVisibleTab is the title of the first sheet shown in the list of
+	// sheet tabs. Default is the first sheet.
+	FirstVisibleTab string
+	// Properties are the document's metadata, such as the title and the
+	// author. They are written on Close(), and are optional.
+	Properties       *Properties
+	styleCache       map[string]int
+	parts            contentTypes // besides the sheets
+	tableCount       int          // in the workbook
+	tableNames       []string
+	media            map[[32]byte]string    // sha256 -> part name, for images
+	chartCount       int                    // in the workbook
+	vmlBlock         int                    // last used block of VML shape IDs
+	header           sheetHeader            // settings for the start of the open sheet
+	definedNames     []definedName          // written in the workbook
+	sheetState       string                 // of the open sheet
+	sheetStates      []string               // of the finished sheets
+	protection       *workbookProtectionXML // see ProtectWorkbook()
+	customProperties []customProperty
+	rolledOver       int   // number of finished continuation sheets of the open sheet
+	error            error // returned with Close()
 }
 
 // New creates a new file. Do Close() it afterwards. No need to check every
@@ -29,13 +100,13 @@ func New(w io.Writer) *StreamXLSX {
 		zip:        zip.NewWriter(w),
 		Styles:     &Stylesheet{},
 		styleCache: map[string]int{},
+		media:      map[[32]byte]string{},
 	}
 
 	// empty style. Not 100% it's needed
 	styleID := s.Styles.GetCellStyleID(Xf{})
 	s.Styles.GetCellID(Xf{XfID: &styleID})
 
-	s.writeRelations()
 	return s
 }
 
@@ -52,10 +123,49 @@ func New(w io.Writer) *StreamXLSX {
 //	[]byte: will be base64 encoded
 //	time.Time: handled, but you need to Format() it. For example: s.Format("mm-dd-yy", aTimeTime)
 //	Hyperlink{}: will make the cell a hyperlink
+//	Comment{}: adds a note to the cell
 //	Cell{}: if you want to set everything manually
 //
 // See Format() to apply number formatting to cells.
 func (s *StreamXLSX) WriteRow(vs ...interface{}) error {
+	return s.WriteRowWithOptions(RowOptions{}, vs...)
+}
+
+// WriteRowWithOptions is WriteRow(), with a custom height, outline level, &c.
+// for the row.
+func (s *StreamXLSX) WriteRowWithOptions(opts RowOptions, vs ...interface{}) error {
+	if s.error != nil {
+		return s.error
+	}
+	if opts.Format != "" {
+		opts.style = s.formatID(opts.Format)
+	}
+
+	sh, err := s.sheet()
+	if err != nil {
+		s.error = err
+		return err
+	}
+	if s.Rollover && sh.rows >= maxRows {
+		if sh, err = s.rollover(); err != nil {
+			s.error = err
+			return err
+		}
+	}
+	if err := sh.writeRowAt(sh.rows, sh.firstColumn(), opts, vs...); err != nil {
+		s.error = err
+		return err
+	}
+	return nil
+}
+
+// WriteRowAt writes a row at the given (0-based) row, with the first value in
+// the given (0-based) column. Rows must be written in increasing order, but
+// they don't need to be consecutive: skipped rows are left empty. WriteRow()
+// continues after the last written row.
+//
+// See WriteRow() for the supported values. Rollover is not applied.
+func (s *StreamXLSX) WriteRowAt(row, col int, vs ...interface{}) error {
 	if s.error != nil {
 		return s.error
 	}
@@ -65,7 +175,7 @@ func (s *StreamXLSX) WriteRow(vs ...interface{}) error {
 		s.error = err
 		return err
 	}
-	if err := sh.writeRow(vs...); err != nil {
+	if err := sh.writeRowAt(row, col, RowOptions{}, vs...); err != nil {
 		s.error = err
 		return err
 	}
@@ -76,35 +186,133 @@ func (s *StreamXLSX) WriteRow(vs ...interface{}) error {
 // The process is you first do all the `WriteRow()`s for a sheet, followed by
 // its WriteSheet().  There is always an open sheet. You don't have to close
 // the final sheet, but it'll give you a boring name ("sheet N").
+//
+// Titles are validated against the rules of Excel: at most 31 characters, none
+// of `:\/?*[]`, no apostrophe at the start or end, not "History", and unique
+// (case insensitive). See SanitizeSheetNames.
 func (s *StreamXLSX) WriteSheet(title string) error {
 	if s.error != nil {
 		return s.error
 	}
 
+	titles, err := s.sheetTitles(title)
+	if err != nil {
+		s.error = err
+		return err
+	}
+	if err := s.closeSheet(titles[len(titles)-1]); err != nil {
+		s.error = err
+		return err
+	}
+	// this includes the earlier parts of a rolled over sheet
+	copy(s.finishedSheets[len(s.finishedSheets)-len(titles):], titles)
+	s.rolledOver = 0
+	s.header = sheetHeader{}
+	s.sheetState = ""
+	return nil
+}
+
+// sheetTitles gives the validated titles for the open sheet and its rolled
+// over parts.
+func (s *StreamXLSX) sheetTitles(title string) ([]string, error) {
+	var (
+		taken  = append([]string(nil), s.finishedSheets[:len(s.finishedSheets)-s.rolledOver]...)
+		titles []string
+	)
+	for i := 0; i <= s.rolledOver; i++ {
+		t := title
+		if s.SanitizeSheetNames {
+			// duplicates get the " (N)" suffix
+			t = sanitizeSheetName(t, taken)
+		} else {
+			if i > 0 {
+				// shorten the title to make room for the suffix
+				suffix := fmt.Sprintf(" (%d)", i+1)
+				t = truncateRunes(title, MaxSheetNameLength-len(suffix)) + suffix
+			}
+			if err := validateSheetName(t, taken); err != nil {
+				return nil, err
+			}
+		}
+		taken = append(taken, t)
+		titles = append(titles, t)
+	}
+	return titles, nil
+}
+
+func (s *StreamXLSX) closeSheet(title string) error {
 	// make sure there is a sheet open
 	if _, err := s.sheet(); err != nil {
-		s.error = err
 		return err
 	}
-	s.openSheet.Close()
+	defer s.openSheet.links.remove()
+	if s.openSheet.openTable != nil {
+		if err := s.openSheet.endTable(); err != nil {
+			return err
+		}
+	}
+	if err := s.openSheet.Close(); err != nil {
+		return err
+	}
+	if err := s.addPrintNames(); err != nil {
+		return err
+	}
+	s.addSheetNames()
 	if err := s.writeSheetRelations(); err != nil { // for hyperlink refs
-		s.error = err
+		return err
+	}
+	if err := s.writeComments(); err != nil {
+		return err
+	}
+	if err := s.writeTables(); err != nil {
+		return err
+	}
+	if err := s.writeDrawing(); err != nil {
 		return err
 	}
 	s.openSheet = nil
 	s.finishedSheets = append(s.finishedSheets, title)
+	s.sheetStates = append(s.sheetStates, s.sheetState)
 	return nil
 }
 
+// rollover closes the full open sheet and opens the continuation sheet.
+// Titles are set when the sheet is closed via WriteSheet().
+func (s *StreamXLSX) rollover() (*sheetEncoder, error) {
+	groups, protection := s.openSheet.rowGroups, s.openSheet.protection
+	if err := s.closeSheet(""); err != nil {
+		return nil, err
+	}
+	s.rolledOver++
+	sh, err := s.sheet()
+	if err != nil {
+		return nil, err
+	}
+	// the header isn't part of the open row groups
+	if len(s.RolloverHeader) > 0 {
+		if err := sh.writeRow(s.RolloverHeader...); err != nil {
+			return nil, err
+		}
+	}
+	sh.rowGroups = groups
+	sh.protection = protection
+	return sh, nil
+}
+
 // Adds a number format to a cell. Examples of formats are "0.00", "0%", ...
 // This is used to wrap a value in a WriteRow().
 func (s *StreamXLSX) Format(code string, cell interface{}) Cell {
+	c, err := applyStyle(s.formatID(code), cell)
+	if err != nil {
+		s.error = err
+	}
+	return c
+}
+
+// formatID gives the CellXf ID for a number format.
+func (s *StreamXLSX) formatID(code string) int {
 	if xfID, ok := s.styleCache[code]; ok {
-		c, err := applyStyle(xfID, cell)
-		if err != nil {
-			s.error = err
-		}
-		return c
+		return xfID
 	}
 
 	numFmtID := s.Styles.GetNumFmtID(code)
@@ -116,23 +324,56 @@ func (s *StreamXLSX) Format(code string, cell interface{}) Cell {
 	}
 	xfID := s.Styles.GetCellID(styleFx)
 	s.styleCache[code] = xfID
-	c, err := applyStyle(xfID, cell)
-	if err != nil {
-		s.error = err
-	}
-	return c
+	return xfID
 }
 
 // Adds a hyperlink in a cell. You can use these as a value in WriteRow().
 // (implementation detail: parts of the hyperlink datastructure is
 // only written when closing a sheet, so they are buffered)
+//
+// Title is the cell value, which can be anything WriteRow() accepts, including
+// Format()ed values. The URL is used if it's nil.
+//
+// A URL starting with "#" links to a location in the workbook, such as
+// "#'Details'!A1" or "#SomeDefinedName". See LocationLink().
 type Hyperlink struct {
-	URL, Title, Tooltip string
+	URL     string
+	Title   interface{}
+	Tooltip string
+}
+
+// LocationLink makes a hyperlink to a location in the workbook. A location is
+// a cell ref on a sheet ("'Details'!A1", see SheetRef()) or a defined name.
+func LocationLink(location string, title interface{}) Hyperlink {
+	return Hyperlink{URL: "#" + location, Title: title}
+}
+
+// MailtoLink makes a hyperlink to an email address.
+func MailtoLink(address string, title interface{}) Hyperlink {
+	return Hyperlink{URL: "mailto:" + address, Title: title}
+}
+
+// FileLink makes a hyperlink to a file. Relative paths are relative to the
+// .xlsx file.
+func FileLink(path string, title interface{}) Hyperlink {
+	p := filepath.ToSlash(path)
+	if filepath.IsAbs(path) {
+		p = "file:///" + strings.TrimPrefix(p, "/")
+	}
+	return Hyperlink{URL: p, Title: title}
+}
+
+// SheetRef makes a ref to a cell or range on a sheet, such as "'My Sheet'!A1".
+func SheetRef(sheet, ref string) string {
+	return "'" + strings.ReplaceAll(sheet, "'", "''") + "'!" + ref
 }
 
 // Finish writing the spreadsheet.
 func (s *StreamXLSX) Close() error {
 	if s.error != nil {
+		if s.openSheet != nil {
+			s.openSheet.links.remove()
+		}
 		return s.error
 	}
 
@@ -143,7 +384,10 @@ func (s *StreamXLSX) Close() error {
 		}
 	}
 	if s.openSheet != nil {
-		if err := s.WriteSheet(fmt.Sprintf("sheet %d", len(s.finishedSheets)+1)); err != nil {
+		// the default title can't clash with a title given to WriteSheet()
+		taken := s.finishedSheets[:len(s.finishedSheets)-s.rolledOver]
+		title := sanitizeSheetName(fmt.Sprintf("sheet %d", len(taken)+1), taken)
+		if err := s.WriteSheet(title); err != nil {
 			return err
 		}
 
@@ -161,6 +405,15 @@ func (s *StreamXLSX) Close() error {
 	if err := s.writeWorkbookRelations(); err != nil {
 		return err
 	}
+	if err := s.writeDocProps(); err != nil {
+		return err
+	}
+	if err := s.writeCustomProperties(); err != nil {
+		return err
+	}
+	if err := s.writeRelations(); err != nil {
+		return err
+	}
 	if err := s.writeContentTypes(); err != nil {
 		return err
 	}
@@ -174,28 +427,47 @@ func (s *StreamXLSX) sheet() (*sheetEncoder, error) {
 	if s.openSheet != nil {
 		return s.openSheet, nil
 	}
-	filename := fmt.Sprintf("xl/worksheets/sheet%d.xml", len(s.finishedSheets)+1)
+	id := len(s.finishedSheets) + 1
+	filename := fmt.Sprintf("xl/worksheets/sheet%d.xml", id)
 	fh, err := s.zip.Create(filename) // no need to close!
 	if err != nil {
 		return nil, err
 	}
 
-	enc, err := newSheetEncoder(fh)
+	enc, err := newSheetEncoder(fh, id, s.header)
 	if err != nil {
 		return nil, err
 	}
+	enc.longStrings = s.LongStrings
+	enc.links.spill = s.SpillHyperlinks
 
 	s.openSheet = enc
 	return s.openSheet, nil
 }
 
 func (s *StreamXLSX) writeWorkbook() error {
+	order, err := s.sheetOrder()
+	if err != nil {
+		return err
+	}
+	active, first, err := s.bookView(order)
+	if err != nil {
+		return err
+	}
 	filename := "xl/workbook.xml"
 	fh, err := s.zip.Create(filename)
 	if err != nil {
 		return err
 	}
-	return writeWorkbook(fh, s.finishedSheets)
+	return writeWorkbook(fh, workbook{
+		titles:     s.finishedSheets,
+		states:     s.sheetStates,
+		names:      s.definedNames,
+		order:      order,
+		protection: s.protection,
+		active:     active,
+		first:      first,
+	})
 }
 
 func (s *StreamXLSX) writeStylesheet() error {
@@ -222,7 +494,7 @@ func (s *StreamXLSX) writeRelations() error {
 	if err != nil {
 		return err
 	}
-	return writeRelations(fh)
+	return writeRelations(fh, s.docPropsRelations())
 }
 
 func (s *StreamXLSX) writeWorkbookRelations() error {
@@ -235,7 +507,8 @@ func (s *StreamXLSX) writeWorkbookRelations() error {
 }
 
 func (s *StreamXLSX) writeSheetRelations() error {
-	if len(s.openSheet.relations) == 0 {
+	sh := s.openSheet
+	if len(sh.relations) == 0 && sh.links.relCount == 0 {
 		return nil
 	}
 	filename := fmt.Sprintf("xl/worksheets/_rels/sheet%d.xml.rels", len(s.finishedSheets)+1)
@@ -243,7 +516,7 @@ func (s *StreamXLSX) writeSheetRelations() error {
 	if err != nil {
 		return err
 	}
-	return writeRelations_(fh, s.openSheet.relations)
+	return writeSheetRelations(fh, sh.relations, &sh.links)
 }
 
 func (s *StreamXLSX) writeContentTypes() error {
@@ -252,5 +525,76 @@ func (s *StreamXLSX) writeContentTypes() error {
 	if err != nil {
 		return err
 	}
-	return writeContentTypes(fh, len(s.finishedSheets))
+	return writeContentTypes(fh, len(s.finishedSheets), s.parts)
+}
+
+func (s *StreamXLSX) writeDrawing() error {
+	sh := s.openSheet
+	if sh.drawing == nil {
+		return nil
+	}
+	if err := s.writeDrawingParts(sh.drawing); err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("xl/drawings/drawing%d.xml", sh.id)
+	fh, err := s.zip.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := writeDrawing(fh, sh.drawing); err != nil {
+		return err
+	}
+	s.parts.addOverride("/"+filename, "application/vnd.openxmlformats-officedocument.drawing+xml")
+
+	filename = fmt.Sprintf("xl/drawings/_rels/drawing%d.xml.rels", sh.id)
+	fh, err = s.zip.Create(filename)
+	if err != nil {
+		return err
+	}
+	return writeRelations_(fh, sh.drawing.relations)
+}
+
+func (s *StreamXLSX) writeTables() error {
+	for _, t := range s.openSheet.tables {
+		filename := fmt.Sprintf("xl/tables/table%d.xml", t.id)
+		fh, err := s.zip.Create(filename)
+		if err != nil {
+			return err
+		}
+		if err := writeTable(fh, t); err != nil {
+			return err
+		}
+		s.parts.addOverride("/"+filename, "application/vnd.openxmlformats-officedocument.spreadsheetml.table+xml")
+	}
+	return nil
+}
+
+func (s *StreamXLSX) writeComments() error {
+	sh := s.openSheet
+	if len(sh.comments) == 0 {
+		return nil
+	}
+	filename := fmt.Sprintf("xl/comments%d.xml", sh.id)
+	fh, err := s.zip.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := writeComments(fh, sh.comments); err != nil {
+		return err
+	}
+	s.parts.addOverride("/"+filename, "application/vnd.openxmlformats-officedocument.spreadsheetml.comments+xml")
+
+	filename = fmt.Sprintf("xl/drawings/vmlDrawing%d.vml", sh.id)
+	fh, err = s.zip.Create(filename)
+	if err != nil {
+		return err
+	}
+	next, err := writeVMLDrawing(fh, s.vmlBlock+1, sh.comments)
+	if err != nil {
+		return err
+	}
+	s.vmlBlock = next - 1
+	s.parts.addDefault("vml", "application/vnd.openxmlformats-officedocument.vmlDrawing")
+	return nil
 }
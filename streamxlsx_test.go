@@ -1,9 +1,16 @@
 package streamxlsx_test
 
 import (
+	"archive/zip"
 	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io/ioutil"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -269,6 +276,1274 @@ func TestWriteError(t *testing.T) {
 	mustEq(t, err.Error(), "write /tmp/streamxlsx.test: file already closed")
 }
 
+func TestWriteRowAt(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.WriteRow("first"))
+	noError(t, s.WriteRowAt(3, 2, "C4", nil, "E4"))
+	noError(t, s.WriteRow("after"))
+	noError(t, s.WriteRowAt(10, 1, "B11"))
+	noError(t, s.Close())
+
+	xf, err := streamxlsx.TestParse(buf.Bytes())
+	noError(t, err)
+	mustDeepEq(t,
+		[]streamxlsx.TestCell{
+			{"A1", "inlineStr", "first", 0},
+			{"C4", "inlineStr", "C4", 0},
+			{"E4", "inlineStr", "E4", 0},
+			{"A5", "inlineStr", "after", 0},
+			{"B11", "inlineStr", "B11", 0},
+		},
+		xf.Sheets[0].Cells,
+	)
+
+	t.Run("order", func(t *testing.T) {
+		s := streamxlsx.New(&bytes.Buffer{})
+		noError(t, s.WriteRowAt(10, 0, "A11"))
+		mustBeError(t, streamxlsx.ErrRowOrder, s.WriteRowAt(10, 0, "again"))
+	})
+}
+
+func TestRowOptions(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.WriteRowWithOptions(streamxlsx.RowOptions{Height: 30.5}, "header"))
+	noError(t, s.WriteRowWithOptions(streamxlsx.RowOptions{Hidden: true, OutlineLevel: 1}, "detail"))
+	noError(t, s.WriteRowWithOptions(streamxlsx.RowOptions{Collapsed: true, Format: "0.00"}, "total", 12))
+	noError(t, s.Close())
+
+	sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	mustContain(t, sheet, `<row r="1" ht="30.5" customHeight="1">`)
+	mustContain(t, sheet, `<row r="2" hidden="1" outlineLevel="1">`)
+	mustContain(t, sheet, `<row r="3" collapsed="1" s="1" customFormat="1">`)
+
+	s = streamxlsx.New(&bytes.Buffer{})
+	if err := s.WriteRowWithOptions(streamxlsx.RowOptions{OutlineLevel: 8}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestOutline(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.SetOutline(streamxlsx.Outline{RowLevels: 2}))
+	noError(t, s.GroupColumns(1, 4, false))
+	noError(t, s.GroupColumns(2, 3, true))
+	noError(t, s.WriteRow("header"))
+	noError(t, s.BeginRowGroup(false))
+	noError(t, s.WriteRow("detail"))
+	noError(t, s.BeginRowGroup(true))
+	noError(t, s.WriteRow("more detail"))
+	noError(t, s.EndRowGroup())
+	noError(t, s.WriteRow("subtotal"))
+	noError(t, s.EndRowGroup())
+	noError(t, s.WriteRow("total"))
+	noError(t, s.Close())
+
+	sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	mustContain(t, sheet, `<sheetPr><outlinePr/></sheetPr><sheetFormatPr defaultRowHeight="15" outlineLevelRow="2" outlineLevelCol="2"/>`)
+	mustContain(t, sheet, `<cols><col min="2" max="2" width="9.140625" outlineLevel="1"/><col min="3" max="4" width="9.140625" hidden="1" outlineLevel="2"/><col min="5" max="5" width="9.140625" outlineLevel="1" collapsed="1"/></cols>`)
+	mustContain(t, sheet, `<row r="1">`)
+	mustContain(t, sheet, `<row r="2" outlineLevel="1">`)
+	mustContain(t, sheet, `<row r="3" hidden="1" outlineLevel="2">`)
+	mustContain(t, sheet, `<row r="4" outlineLevel="1" collapsed="1">`)
+	mustContain(t, sheet, `<row r="5">`)
+
+	t.Run("started", func(t *testing.T) {
+		s := streamxlsx.New(&bytes.Buffer{})
+		noError(t, s.WriteRow("hi"))
+		mustBeError(t, streamxlsx.ErrSheetStarted, s.GroupColumns(1, 2, false))
+	})
+
+	t.Run("levels", func(t *testing.T) {
+		s := streamxlsx.New(&bytes.Buffer{})
+		if err := s.BeginRowGroup(false); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestHyperlinks(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.WriteRow("details"))
+	noError(t, s.WriteSheet("Bob's details"))
+	noError(t, s.WriteRow(
+		streamxlsx.Hyperlink{"http://example.com", "web", ""},
+		streamxlsx.LocationLink(streamxlsx.SheetRef("Bob's details", "A1"), "details"),
+		streamxlsx.MailtoLink("bob@example.com", "mail"),
+		streamxlsx.FileLink("/tmp/report.pdf", "file"),
+	))
+
+	noError(t, s.WriteRow(
+		streamxlsx.Hyperlink{URL: "http://example.com/42", Title: 42, Tooltip: "the answer"},
+		streamxlsx.Hyperlink{URL: "http://example.com/date", Title: s.Format("yyyy-mm-dd", time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC))},
+		streamxlsx.Hyperlink{URL: "http://example.com/plain"},
+	))
+	noError(t, s.Close())
+
+	xf, err := streamxlsx.TestParse(buf.Bytes())
+	noError(t, err)
+	mustDeepEq(t,
+		[]streamxlsx.TestCell{
+			{"A2", "n", "42", 0},
+			{"B2", "n", "43832", 1},
+			{"C2", "inlineStr", "http://example.com/plain", 0},
+		},
+		xf.Sheets[1].Cells[4:],
+	)
+
+	sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet2.xml")
+	mustContain(t, sheet, `<hyperlink ref="A1" r:id="linkId1" display="web"/>`)
+	mustContain(t, sheet, `<hyperlink ref="A2" r:id="linkId4" tooltip="the answer"/>`)
+	mustContain(t, sheet, `<hyperlink ref="B2" r:id="linkId5"/>`)
+	mustContain(t, sheet, `<hyperlink ref="C2" r:id="linkId6" display="http://example.com/plain"/>`)
+	mustContain(t, sheet, `<hyperlink ref="B1" location="&#39;Bob&#39;&#39;s details&#39;!A1" display="details"`)
+	mustContain(t, sheet, `<hyperlink ref="C1" r:id="linkId2" display="mail"`)
+	mustContain(t, sheet, `<hyperlink ref="D1" r:id="linkId3" display="file"`)
+
+	rels := readPart(t, buf.Bytes(), "xl/worksheets/_rels/sheet2.xml.rels")
+	mustContain(t, rels, `Target="mailto:bob@example.com"`)
+	mustContain(t, rels, `Target="file:///tmp/report.pdf"`)
+	if strings.Contains(rels, "details") {
+		t.Fatalf("internal link in relations: %s", rels)
+	}
+
+	t.Run("location without title", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		noError(t, s.WriteRow(streamxlsx.LocationLink("Foo", nil)))
+		noError(t, s.Close())
+
+		sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+		mustContain(t, sheet, `<is><t>Foo</t></is>`)
+		mustContain(t, sheet, `<hyperlink ref="A1" location="Foo" display="Foo"/>`)
+	})
+}
+
+func TestHyperlinkRelations(t *testing.T) {
+	write := func(spill bool) []byte {
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		s.SpillHyperlinks = spill
+		for i := 0; i < 100; i++ {
+			noError(t, s.WriteRow(streamxlsx.Hyperlink{"http://example.com/" + strconv.Itoa(i%3), "link", ""}))
+		}
+		noError(t, s.WriteSheet("links"))
+		noError(t, s.Close())
+		return buf.Bytes()
+	}
+
+	mem := write(false)
+	rels := readPart(t, mem, "xl/worksheets/_rels/sheet1.xml.rels")
+	if have, want := strings.Count(rels, "<Relationship "), 3; have != want {
+		t.Fatalf("have %d, want %d", have, want)
+	}
+	sheet := readPart(t, mem, "xl/worksheets/sheet1.xml")
+	if have, want := strings.Count(sheet, "<hyperlink "), 100; have != want {
+		t.Fatalf("have %d, want %d", have, want)
+	}
+	mustContain(t, sheet, `<hyperlink ref="A4" r:id="linkId1"`)
+
+	spilled := write(true)
+	mustEq(t, rels, readPart(t, spilled, "xl/worksheets/_rels/sheet1.xml.rels"))
+	mustEq(t, sheet, readPart(t, spilled, "xl/worksheets/sheet1.xml"))
+}
+
+func TestComments(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.WriteRow("no comments here"))
+	noError(t, s.WriteSheet("plain"))
+	noError(t, s.WriteRow("value", streamxlsx.Comment{Value: -12, Author: "QA", Text: "negative?"}))
+	noError(t, s.WriteRow(streamxlsx.Comment{
+		Value:  streamxlsx.Hyperlink{URL: "http://example.com", Title: "link"},
+		Author: "Bob & co",
+		Rich: []streamxlsx.RichText{
+			{Text: "Bob: ", Bold: true},
+			{Text: "check this", Color: "ff0000"},
+		},
+	}))
+	noError(t, s.WriteSheet("commented"))
+	noError(t, s.Close())
+
+	xf, err := streamxlsx.TestParse(buf.Bytes())
+	noError(t, err)
+	mustDeepEq(t,
+		[]streamxlsx.TestCell{
+			{"A1", "inlineStr", "value", 0},
+			{"B1", "n", "-12", 0},
+			{"A2", "inlineStr", "link", 0},
+		},
+		xf.Sheets[1].Cells,
+	)
+
+	sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet2.xml")
+	mustContain(t, sheet, `</hyperlinks><legacyDrawing r:id="vmlId1"/></worksheet>`)
+	rels := readPart(t, buf.Bytes(), "xl/worksheets/_rels/sheet2.xml.rels")
+	mustContain(t, rels, `Target="/xl/comments2.xml"`)
+	mustContain(t, rels, `Target="/xl/drawings/vmlDrawing2.vml"`)
+	comments := readPart(t, buf.Bytes(), "xl/comments2.xml")
+	mustContain(t, comments, `<authors><author>QA</author><author>Bob &amp; co</author></authors>`)
+	mustContain(t, comments, `<comment ref="B1" authorId="0"><text><r><t xml:space="preserve">negative?</t></r></text></comment>`)
+	mustContain(t, comments, `<comment ref="A2" authorId="1"><text><r><rPr><b/></rPr><t xml:space="preserve">Bob: </t></r><r><rPr><color rgb="FFFF0000"/></rPr>`)
+	vml := readPart(t, buf.Bytes(), "xl/drawings/vmlDrawing2.vml")
+	mustContain(t, vml, `<x:Row>1</x:Row><x:Column>0</x:Column>`)
+	types := readPart(t, buf.Bytes(), "[Content_Types].xml")
+	mustContain(t, types, `<Default Extension="vml" ContentType="application/vnd.openxmlformats-officedocument.vmlDrawing"/>`)
+	mustContain(t, types, `<Override PartName="/xl/comments2.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.comments+xml"/>`)
+
+	t.Run("empty cell", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		noError(t, s.WriteRow("a", streamxlsx.Comment{Author: "QA", Text: "missing"}))
+		noError(t, s.Close())
+
+		sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+		mustContain(t, sheet, `<c r="B1"></c></row>`)
+		comments := readPart(t, buf.Bytes(), "xl/comments1.xml")
+		mustContain(t, comments, `<comment ref="B1" authorId="0"><text><r><t xml:space="preserve">missing</t></r></text></comment>`)
+	})
+
+	t.Run("many", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		for i := 0; i < 1100; i++ {
+			noError(t, s.WriteRow(streamxlsx.Comment{Value: i, Text: "check"}))
+		}
+		noError(t, s.WriteSheet("many"))
+		noError(t, s.WriteRow(streamxlsx.Comment{Value: 1, Text: "check"}))
+		noError(t, s.Close())
+
+		vml := readPart(t, buf.Bytes(), "xl/drawings/vmlDrawing1.vml")
+		mustContain(t, vml, `<o:idmap v:ext="edit" data="1,2"/>`)
+		mustContain(t, vml, `<v:shape id="_x0000_s2124"`)
+		vml = readPart(t, buf.Bytes(), "xl/drawings/vmlDrawing2.vml")
+		mustContain(t, vml, `<o:idmap v:ext="edit" data="3"/>`)
+		mustContain(t, vml, `<v:shape id="_x0000_s3073"`)
+	})
+
+	t.Run("invalid color", func(t *testing.T) {
+		s := streamxlsx.New(&bytes.Buffer{})
+		err := s.WriteRow(streamxlsx.Comment{Value: 1, Rich: []streamxlsx.RichText{{Text: "hi", Color: "red"}}})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestDataValidation(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.WriteRow("status", "amount", "date"))
+	noError(t, s.AddDataValidation(streamxlsx.ListValidation("A2:A100", "open", "closed")))
+	noError(t, s.AddDataValidation(streamxlsx.DataValidation{
+		Ref:          "B2:B100",
+		Type:         streamxlsx.ValidationDecimal,
+		Operator:     streamxlsx.OperatorGreaterThan,
+		Formula1:     "0",
+		ErrorMessage: "must be > 0",
+	}))
+	noError(t, s.AddDataValidation(streamxlsx.DataValidation{
+		Ref:          "C2:C100",
+		Type:         streamxlsx.ValidationDate,
+		Formula1:     "DATE(2020,1,1)",
+		Formula2:     "DATE(2020,12,31)",
+		AllowBlank:   true,
+		InputMessage: "a date in 2020",
+	}))
+	noError(t, s.WriteRow("open", 12))
+	noError(t, s.Close())
+
+	sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	mustContain(t, sheet, `</sheetData><dataValidations count="3">`)
+	mustContain(t, sheet, `<dataValidation type="list" showErrorMessage="1" sqref="A2:A100"><formula1>&#34;open,closed&#34;</formula1></dataValidation>`)
+	mustContain(t, sheet, `<dataValidation type="decimal" operator="greaterThan" showErrorMessage="1" error="must be &gt; 0" sqref="B2:B100"><formula1>0</formula1></dataValidation>`)
+	mustContain(t, sheet, `<dataValidation type="date" allowBlank="1" showInputMessage="1" showErrorMessage="1" prompt="a date in 2020" sqref="C2:C100"><formula1>DATE(2020,1,1)</formula1><formula2>DATE(2020,12,31)</formula2></dataValidation>`)
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, dv := range []streamxlsx.DataValidation{
+			{Type: streamxlsx.ValidationList, List: []string{"a"}},
+			{Ref: "A1", Type: "nosuch"},
+			{Ref: "A1", Type: streamxlsx.ValidationList, List: []string{"a,b"}},
+			{Ref: "A1", Type: streamxlsx.ValidationWhole, Formula1: "1"},
+			{Ref: "A1", Type: streamxlsx.ValidationWhole, Operator: "lessThen", Formula1: "1"},
+			{Ref: "A1", Type: streamxlsx.ValidationList, List: []string{"a"}, ErrorStyle: "info"},
+		} {
+			s := streamxlsx.New(&bytes.Buffer{})
+			if err := s.AddDataValidation(dv); err == nil {
+				t.Fatalf("expected an error for %#v", dv)
+			}
+		}
+	})
+}
+
+func TestConditionalFormatting(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.WriteRow("balance", -12))
+	red := &streamxlsx.Dxf{FontColor: "9C0006", FillColor: "FFC7CE"}
+	noError(t, s.AddConditionalFormatting("B1:B100",
+		streamxlsx.ConditionalRule{Type: streamxlsx.CondCellIs, Operator: streamxlsx.OperatorLessThan, Formula: []string{"0"}, Format: red},
+		streamxlsx.ConditionalRule{Type: streamxlsx.CondExpression, Formula: []string{"$B1>1000"}, Format: &streamxlsx.Dxf{Bold: true, NumFmt: "0.000"}},
+	))
+	noError(t, s.AddConditionalFormatting("C1:C100", streamxlsx.ConditionalRule{Type: streamxlsx.CondDataBar, Color: "638EC6"}))
+	noError(t, s.AddConditionalFormatting("D1:D100", streamxlsx.ConditionalRule{Type: streamxlsx.CondColorScale, Colors: []string{"F8696B", "FFEB84", "63BE7B"}}))
+	noError(t, s.AddConditionalFormatting("E1:E100", streamxlsx.ConditionalRule{Type: streamxlsx.CondIconSet, IconSet: "4Arrows"}))
+	noError(t, s.AddConditionalFormatting("F1:F100",
+		streamxlsx.ConditionalRule{Type: streamxlsx.CondTop10, Rank: 5, Percent: true, Format: red},
+		streamxlsx.ConditionalRule{Type: streamxlsx.CondDuplicateValues, Format: red},
+	))
+	noError(t, s.Close())
+
+	sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	mustContain(t, sheet, `</sheetData><conditionalFormatting sqref="B1:B100"><cfRule type="cellIs" dxfId="0" priority="1" operator="lessThan"><formula>0</formula></cfRule><cfRule type="expression" dxfId="1" priority="2"><formula>$B1&gt;1000</formula></cfRule></conditionalFormatting>`)
+	mustContain(t, sheet, `<cfRule type="dataBar" priority="3"><dataBar><cfvo type="min"/><cfvo type="max"/><color rgb="FF638EC6"/></dataBar></cfRule>`)
+	mustContain(t, sheet, `<cfRule type="colorScale" priority="4"><colorScale><cfvo type="min"/><cfvo type="percentile" val="50"/><cfvo type="max"/><color rgb="FFF8696B"/><color rgb="FFFFEB84"/><color rgb="FF63BE7B"/></colorScale></cfRule>`)
+	mustContain(t, sheet, `<iconSet iconSet="4Arrows"><cfvo type="percent" val="0"/><cfvo type="percent" val="25"/><cfvo type="percent" val="50"/><cfvo type="percent" val="75"/></iconSet>`)
+	mustContain(t, sheet, `<cfRule type="top10" dxfId="0" priority="6" rank="5" percent="1"></cfRule><cfRule type="duplicateValues" dxfId="0" priority="7"></cfRule>`)
+
+	styles := readPart(t, buf.Bytes(), "xl/styles.xml")
+	mustContain(t, styles, `<dxfs count="2"><dxf><font><color rgb="FF9C0006"></color></font><fill><patternFill><bgColor rgb="FFFFC7CE"></bgColor></patternFill></fill></dxf><dxf><font><b></b></font><numFmt numFmtId="164" formatCode="0.000"></numFmt></dxf></dxfs>`)
+	mustContain(t, styles, `<numFmts count="1"><numFmt numFmtId="164" formatCode="0.000"></numFmt></numFmts>`)
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, r := range []streamxlsx.ConditionalRule{
+			{Type: "nosuch"},
+			{Type: streamxlsx.CondCellIs, Operator: streamxlsx.OperatorBetween, Formula: []string{"1"}},
+			{Type: streamxlsx.CondCellIs, Operator: "lessThen", Formula: []string{"1"}},
+			{Type: streamxlsx.CondColorScale, Colors: []string{"FF0000"}},
+			{Type: streamxlsx.CondIconSet, IconSet: "Arrows"},
+			{Type: streamxlsx.CondColorScale, Colors: []string{"FF0000", "green"}},
+			{Type: streamxlsx.CondDataBar, Color: "12345"},
+			{Type: streamxlsx.CondDuplicateValues, Format: &streamxlsx.Dxf{FillColor: "yellow"}},
+		} {
+			s := streamxlsx.New(&bytes.Buffer{})
+			if err := s.AddConditionalFormatting("A1", r); err == nil {
+				t.Fatalf("expected an error for %#v", r)
+			}
+		}
+	})
+}
+
+func TestTables(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.WriteRow("sales report"))
+	noError(t, s.StartTable(streamxlsx.Table{
+		Name: "Sales",
+		Columns: []streamxlsx.TableColumn{
+			{Name: "Product", TotalsLabel: "Total"},
+			{Name: "Amount [EUR]", TotalsFunction: "sum"},
+		},
+		BandedRows: true,
+		TotalsRow:  true,
+	}))
+	noError(t, s.WriteRow("apples", 12))
+	noError(t, s.WriteRow("pears", 14))
+	noError(t, s.EndTable())
+	noError(t, s.WriteSheet("sales"))
+	noError(t, s.StartTable(streamxlsx.Table{
+		Columns: []streamxlsx.TableColumn{{Name: "a"}, {Name: "b"}},
+		Column:  1,
+	}))
+	noError(t, s.Close())
+
+	sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	mustContain(t, sheet, `<c r="B5"><f>SUBTOTAL(109,Sales[Amount &#39;[EUR&#39;]])</f></c>`)
+	mustContain(t, sheet, `<tableParts count="1"><tablePart r:id="tableId1"/></tableParts></worksheet>`)
+	table := readPart(t, buf.Bytes(), "xl/tables/table1.xml")
+	mustContain(t, table, `<table xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" id="1" name="Sales" displayName="Sales" ref="A2:B5" totalsRowCount="1"><autoFilter ref="A2:B4"/>`)
+	mustContain(t, table, `<tableColumn id="1" name="Product" totalsRowLabel="Total"/><tableColumn id="2" name="Amount [EUR]" totalsRowFunction="sum"/>`)
+	mustContain(t, table, `showRowStripes="1" showColumnStripes="0"/>`)
+
+	// closed by WriteSheet, without data rows
+	table = readPart(t, buf.Bytes(), "xl/tables/table2.xml")
+	mustContain(t, table, `id="2" name="Table2" displayName="Table2" ref="B1:C2" totalsRowShown="0"><autoFilter ref="B1:C2"/>`)
+	rels := readPart(t, buf.Bytes(), "xl/worksheets/_rels/sheet2.xml.rels")
+	mustContain(t, rels, `Target="/xl/tables/table2.xml"`)
+	types := readPart(t, buf.Bytes(), "[Content_Types].xml")
+	mustContain(t, types, `<Override PartName="/xl/tables/table2.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.table+xml"/>`)
+
+	t.Run("column", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		noError(t, s.StartTable(streamxlsx.Table{
+			Columns: []streamxlsx.TableColumn{{Name: "a"}, {Name: "b"}},
+			Column:  2,
+		}))
+		noError(t, s.WriteRow(1, 2))
+		noError(t, s.WriteRow(3, 4))
+		noError(t, s.EndTable())
+		noError(t, s.WriteRow("after"))
+		noError(t, s.Close())
+
+		table := readPart(t, buf.Bytes(), "xl/tables/table1.xml")
+		mustContain(t, table, `ref="C1:D3"`)
+		xf, err := streamxlsx.TestParse(buf.Bytes())
+		noError(t, err)
+		mustDeepEq(t,
+			[]streamxlsx.TestCell{
+				{"C1", "inlineStr", "a", 0},
+				{"D1", "inlineStr", "b", 0},
+				{"C2", "n", "1", 0},
+				{"D2", "n", "2", 0},
+				{"C3", "n", "3", 0},
+				{"D3", "n", "4", 0},
+				{"A4", "inlineStr", "after", 0},
+			},
+			xf.Sheets[0].Cells,
+		)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		noError(t, s.StartTable(streamxlsx.Table{Columns: []streamxlsx.TableColumn{{Name: "a"}}}))
+		noError(t, s.EndTable())
+		noError(t, s.WriteRow("after table"))
+		noError(t, s.Close())
+
+		table := readPart(t, buf.Bytes(), "xl/tables/table1.xml")
+		mustContain(t, table, `ref="A1:A2"`)
+		sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+		mustContain(t, sheet, `<row r="3"><c r="A3" t="inlineStr"><is><t>after table</t></is></c></row>`)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, tab := range []streamxlsx.Table{
+			{},
+			{Columns: []streamxlsx.TableColumn{{Name: "a"}, {Name: "A"}}},
+			{Columns: []streamxlsx.TableColumn{{Name: ""}}},
+			{Name: "A1", Columns: []streamxlsx.TableColumn{{Name: "a"}}},
+			{Name: "with space", Columns: []streamxlsx.TableColumn{{Name: "a"}}},
+			{Columns: []streamxlsx.TableColumn{{Name: "a", TotalsFunction: "nosuch"}}},
+		} {
+			s := streamxlsx.New(&bytes.Buffer{})
+			if err := s.StartTable(tab); err == nil {
+				t.Fatalf("expected an error for %#v", tab)
+			}
+		}
+
+		s := streamxlsx.New(&bytes.Buffer{})
+		noError(t, s.StartTable(streamxlsx.Table{Name: "dup", Columns: []streamxlsx.TableColumn{{Name: "a"}}}))
+		noError(t, s.WriteSheet("one"))
+		if err := s.StartTable(streamxlsx.Table{Name: "DUP", Columns: []streamxlsx.TableColumn{{Name: "a"}}}); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestImages(t *testing.T) {
+	logo := &bytes.Buffer{}
+	noError(t, png.Encode(logo, image.NewRGBA(image.Rect(0, 0, 20, 10))))
+
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.AddImage(streamxlsx.Image{Data: logo.Bytes(), Ref: "A1", Description: "our logo"}))
+	noError(t, s.WriteRow("report"))
+	noError(t, s.AddImage(streamxlsx.Image{Data: logo.Bytes(), Ref: "B2", ToRef: "D5"}))
+	noError(t, s.WriteSheet("one"))
+	noError(t, s.AddImage(streamxlsx.Image{Data: logo.Bytes(), Ref: "C3", Width: 40, Height: 20}))
+	noError(t, s.Close())
+
+	sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	mustContain(t, sheet, `</sheetData><drawing r:id="drawingId1"/></worksheet>`)
+	rels := readPart(t, buf.Bytes(), "xl/worksheets/_rels/sheet1.xml.rels")
+	mustContain(t, rels, `Target="/xl/drawings/drawing1.xml"`)
+
+	drawing := readPart(t, buf.Bytes(), "xl/drawings/drawing1.xml")
+	mustContain(t, drawing, `<xdr:oneCellAnchor><xdr:from><xdr:col>0</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>0</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:from><xdr:ext cx="190500" cy="95250"/>`)
+	mustContain(t, drawing, `<xdr:cNvPr id="2" name="Picture 1" descr="our logo"/>`)
+	mustContain(t, drawing, `<xdr:twoCellAnchor editAs="oneCell"><xdr:from><xdr:col>1</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>1</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:from><xdr:to><xdr:col>3</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>4</xdr:row>`)
+	mustContain(t, drawing, `<a:blip r:embed="rId2"/>`)
+	drawingRels := readPart(t, buf.Bytes(), "xl/drawings/_rels/drawing1.xml.rels")
+	mustContain(t, drawingRels, `Id="rId2" Target="/xl/media/image1.png"`)
+
+	// same image, so no new media file
+	drawing = readPart(t, buf.Bytes(), "xl/drawings/drawing2.xml")
+	mustContain(t, drawing, `<xdr:ext cx="381000" cy="190500"/>`)
+	drawingRels = readPart(t, buf.Bytes(), "xl/drawings/_rels/drawing2.xml.rels")
+	mustContain(t, drawingRels, `Id="rId1" Target="/xl/media/image1.png"`)
+
+	types := readPart(t, buf.Bytes(), "[Content_Types].xml")
+	mustContain(t, types, `<Default Extension="png" ContentType="image/png"/>`)
+	mustContain(t, types, `<Override PartName="/xl/drawings/drawing2.xml" ContentType="application/vnd.openxmlformats-officedocument.drawing+xml"/>`)
+
+	t.Run("invalid", func(t *testing.T) {
+		s := streamxlsx.New(&bytes.Buffer{})
+		if err := s.AddImage(streamxlsx.Image{Data: []byte("no image"), Ref: "A1"}); err == nil {
+			t.Fatal("expected an error")
+		}
+		s = streamxlsx.New(&bytes.Buffer{})
+		if err := s.AddImage(streamxlsx.Image{Data: logo.Bytes(), Ref: "1A"}); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestCharts(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.WriteRow("week", "visitors"))
+	noError(t, s.WriteRow("w1", 12))
+	noError(t, s.WriteRow("w2", 14))
+	noError(t, s.WriteSheet("Data"))
+	noError(t, s.AddChart(streamxlsx.Chart{
+		Type:  streamxlsx.ChartColumn,
+		Title: "Visitors",
+		Series: []streamxlsx.ChartSeries{
+			{
+				Name:       "visitors",
+				Categories: streamxlsx.SheetRef("Data", "$A$2:$A$3"),
+				Values:     streamxlsx.SheetRef("Data", "$B$2:$B$3"),
+				Color:      "4472c4",
+			},
+		},
+		YAxisTitle: "count",
+		Ref:        "B2",
+	}))
+	noError(t, s.AddChart(streamxlsx.Chart{
+		Type:   streamxlsx.ChartPie,
+		Series: []streamxlsx.ChartSeries{{Values: streamxlsx.SheetRef("Data", "$B$2:$B$3")}},
+		Legend: "none",
+		Ref:    "B20",
+		ToRef:  "H30",
+	}))
+	noError(t, s.WriteSheet("Charts"))
+	noError(t, s.Close())
+
+	drawing := readPart(t, buf.Bytes(), "xl/drawings/drawing2.xml")
+	mustContain(t, drawing, `<xdr:ext cx="4572000" cy="2743200"/><xdr:graphicFrame macro=""><xdr:nvGraphicFramePr><xdr:cNvPr id="2" name="Chart 1"/>`)
+	mustContain(t, drawing, `<c:chart xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart" r:id="rId2"/>`)
+	drawingRels := readPart(t, buf.Bytes(), "xl/drawings/_rels/drawing2.xml.rels")
+	mustContain(t, drawingRels, `Id="rId1" Target="/xl/charts/chart1.xml"`)
+
+	chart := readPart(t, buf.Bytes(), "xl/charts/chart1.xml")
+	mustContain(t, chart, `<c:title><c:tx><c:rich><a:bodyPr/><a:p><a:r><a:t>Visitors</a:t></a:r></a:p></c:rich></c:tx><c:overlay val="0"/></c:title>`)
+	mustContain(t, chart, `<c:barChart><c:barDir val="col"/>`)
+	mustContain(t, chart, `<c:ser><c:idx val="0"/><c:order val="0"/><c:tx><c:v>visitors</c:v></c:tx><c:spPr><a:solidFill><a:srgbClr val="4472C4"/></a:solidFill></c:spPr><c:cat><c:strRef><c:f>&#39;Data&#39;!$A$2:$A$3</c:f></c:strRef></c:cat><c:val><c:numRef><c:f>&#39;Data&#39;!$B$2:$B$3</c:f></c:numRef></c:val></c:ser>`)
+	mustContain(t, chart, `<c:valAx><c:axId val="2"/><c:scaling><c:orientation val="minMax"/></c:scaling><c:delete val="0"/><c:axPos val="l"/><c:majorGridlines/><c:title>`)
+	mustContain(t, chart, `<c:legend><c:legendPos val="r"/>`)
+	chart = readPart(t, buf.Bytes(), "xl/charts/chart2.xml")
+	mustContain(t, chart, `<c:autoTitleDeleted val="1"/><c:plotArea><c:layout/><c:pieChart>`)
+	if strings.Contains(chart, "<c:legend>") {
+		t.Fatal("unexpected legend")
+	}
+	types := readPart(t, buf.Bytes(), "[Content_Types].xml")
+	mustContain(t, types, `<Override PartName="/xl/charts/chart2.xml" ContentType="application/vnd.openxmlformats-officedocument.drawingml.chart+xml"/>`)
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, c := range []streamxlsx.Chart{
+			{Type: "nosuch", Series: []streamxlsx.ChartSeries{{Values: "A1:A2"}}, Ref: "A1"},
+			{Type: streamxlsx.ChartLine, Ref: "A1"},
+			{Type: streamxlsx.ChartLine, Series: []streamxlsx.ChartSeries{{Values: "A1:A2"}}},
+			{Type: streamxlsx.ChartLine, Series: []streamxlsx.ChartSeries{{}}, Ref: "A1"},
+			{Type: streamxlsx.ChartLine, Series: []streamxlsx.ChartSeries{{Values: "A1:A2"}}, Ref: "D5", ToRef: "A1"},
+		} {
+			s := streamxlsx.New(&bytes.Buffer{})
+			if err := s.AddChart(c); err == nil {
+				t.Fatalf("expected an error for %#v", c)
+			}
+		}
+	})
+}
+
+func TestSparklines(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.WriteRow("apples", 1, 3, 2, 5))
+	noError(t, s.WriteRow("pears", 4, -1, 2, 1))
+	min := 0.0
+	noError(t, s.AddSparklines(streamxlsx.SparklineGroup{
+		Sparklines: []streamxlsx.Sparkline{
+			{Ref: "F1", Data: streamxlsx.SheetRef("fruit", "B1:E1")},
+			{Ref: "F2", Data: streamxlsx.SheetRef("fruit", "B2:E2")},
+		},
+		Markers: true,
+		Color:   "00ff00",
+		AxisMin: &min,
+	}))
+	noError(t, s.AddSparklines(streamxlsx.SparklineGroup{
+		Type:       streamxlsx.SparklineWinLoss,
+		Sparklines: []streamxlsx.Sparkline{{Ref: "G2", Data: "'Other'!B2:E2"}},
+		SameAxis:   true,
+	}))
+	noError(t, s.WriteSheet("fruit"))
+	noError(t, s.Close())
+
+	sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	mustContain(t, sheet, `</sheetData><extLst><ext uri="{05C60535-1F16-4fd2-B633-F4F36F0B64E0}" xmlns:x14="http://schemas.microsoft.com/office/spreadsheetml/2009/9/main"><x14:sparklineGroups xmlns:xm="http://schemas.microsoft.com/office/excel/2006/main">`)
+	mustContain(t, sheet, `<x14:sparklineGroup manualMin="0" displayEmptyCellsAs="gap" markers="1" minAxisType="custom"><x14:colorSeries rgb="FF00FF00"/>`)
+	mustContain(t, sheet, `<x14:sparkline><xm:f>&#39;fruit&#39;!B1:E1</xm:f><xm:sqref>F1</xm:sqref></x14:sparkline>`)
+	mustContain(t, sheet, `<x14:sparklineGroup type="stacked" displayEmptyCellsAs="gap" minAxisType="group" maxAxisType="group">`)
+	mustContain(t, sheet, `<xm:f>&#39;Other&#39;!B2:E2</xm:f>`)
+	mustContain(t, sheet, `</x14:sparklineGroups></ext></extLst></worksheet>`)
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, g := range []streamxlsx.SparklineGroup{
+			{},
+			{Type: "pie", Sparklines: []streamxlsx.Sparkline{{Ref: "A1", Data: "'x'!B1:C1"}}},
+			{Sparklines: []streamxlsx.Sparkline{{Ref: "A1:A2", Data: "'x'!B1:C1"}}},
+			{Sparklines: []streamxlsx.Sparkline{{Ref: "A1"}}},
+			{Sparklines: []streamxlsx.Sparkline{{Ref: "A1", Data: "B1:C1"}}},
+			{Sparklines: []streamxlsx.Sparkline{{Ref: "A1", Data: "'x'!B1:C1"}}, HighColor: "blue"},
+		} {
+			s := streamxlsx.New(&bytes.Buffer{})
+			if err := s.AddSparklines(g); err == nil {
+				t.Fatalf("expected an error for %#v", g)
+			}
+		}
+	})
+}
+
+func TestPageSetup(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.SetPageSetup(streamxlsx.PageSetup{
+		Landscape:          true,
+		PaperSize:          streamxlsx.PaperA4,
+		FitToWidth:         1,
+		Margins:            &streamxlsx.PageMargins{Left: 0.5, Right: 0.5, Top: 1, Bottom: 1, Header: 0.25, Footer: 0.25},
+		Gridlines:          true,
+		CenterHorizontally: true,
+	}))
+	noError(t, s.WriteRow("hello"))
+	noError(t, s.WriteSheet("print"))
+	noError(t, s.WriteRow("plain"))
+	noError(t, s.WriteSheet("plain"))
+	noError(t, s.Close())
+
+	sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	mustContain(t, sheet, `<sheetPr><pageSetUpPr fitToPage="1"/></sheetPr>`)
+	mustContain(t, sheet, `</sheetData><printOptions horizontalCentered="1" gridLines="1"/><pageMargins left="0.5" right="0.5" top="1" bottom="1" header="0.25" footer="0.25"/><pageSetup paperSize="9" fitToWidth="1" fitToHeight="0" orientation="landscape"/></worksheet>`)
+
+	plain := readPart(t, buf.Bytes(), "xl/worksheets/sheet2.xml")
+	if strings.Contains(plain, "pageSetup") {
+		t.Fatalf("unexpected page setup: %s", plain)
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		noError(t, s.SetPageSetup(streamxlsx.PageSetup{Scale: 80}))
+		noError(t, s.Close())
+		sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+		mustContain(t, sheet, `<pageMargins left="0.7" right="0.7" top="0.75" bottom="0.75" header="0.3" footer="0.3"/><pageSetup scale="80" orientation="portrait"/>`)
+		if strings.Contains(sheet, "sheetPr") {
+			t.Fatalf("unexpected sheetPr: %s", sheet)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		s := streamxlsx.New(&bytes.Buffer{})
+		if err := s.SetPageSetup(streamxlsx.PageSetup{Scale: 500}); err == nil {
+			t.Fatal("expected an error")
+		}
+
+		s = streamxlsx.New(&bytes.Buffer{})
+		noError(t, s.WriteRow("hello"))
+		mustBeError(t, streamxlsx.ErrSheetStarted, s.SetPageSetup(streamxlsx.PageSetup{}))
+	})
+}
+
+func TestPrintTitles(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.WriteRow("first"))
+	noError(t, s.WriteSheet("first"))
+	noError(t, s.SetPageSetup(streamxlsx.PageSetup{
+		Footer:       "&CPage &P of &N",
+		TitleRows:    "1:2",
+		TitleColumns: "A",
+		PrintArea:    "A1:F20",
+	}))
+	noError(t, s.WriteRow("header"))
+	noError(t, s.AddRowBreak(10))
+	noError(t, s.AddRowBreak(5))
+	noError(t, s.AddColumnBreak(3))
+	noError(t, s.WriteSheet("Bob's"))
+	noError(t, s.Close())
+
+	sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet2.xml")
+	mustContain(t, sheet, `<headerFooter><oddFooter>&amp;CPage &amp;P of &amp;N</oddFooter></headerFooter><rowBreaks count="2" manualBreakCount="2"><brk id="5" max="16383" man="1"/><brk id="10" max="16383" man="1"/></rowBreaks><colBreaks count="1" manualBreakCount="1"><brk id="3" max="1048575" man="1"/></colBreaks></worksheet>`)
+
+	workbook := readPart(t, buf.Bytes(), "xl/workbook.xml")
+	mustContain(t, workbook, `<definedNames><definedName name="_xlnm.Print_Titles" localSheetId="1">&#39;Bob&#39;&#39;s&#39;!$A:$A,&#39;Bob&#39;&#39;s&#39;!$1:$2</definedName><definedName name="_xlnm.Print_Area" localSheetId="1">&#39;Bob&#39;&#39;s&#39;!$A$1:$F$20</definedName></definedNames>`)
+
+	t.Run("errors", func(t *testing.T) {
+		for _, p := range []streamxlsx.PageSetup{
+			{TitleRows: "A:B"},
+			{TitleColumns: "1"},
+			{PrintArea: "A1:"},
+			{Header: strings.Repeat("x", 256)},
+		} {
+			s := streamxlsx.New(&bytes.Buffer{})
+			if err := s.SetPageSetup(p); err == nil {
+				t.Fatalf("expected an error for %#v", p)
+			}
+		}
+
+		s := streamxlsx.New(&bytes.Buffer{})
+		if err := s.AddRowBreak(0); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestDefinedNames(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.AddDefinedName(streamxlsx.DefinedName{Name: "TaxRate", Value: "0.21", Comment: "VAT"}))
+	noError(t, s.AddDefinedName(streamxlsx.DefinedName{Name: "Total", Value: "=SUM(Prices)"}))
+	noError(t, s.WriteRow("apple", 1.5))
+	noError(t, s.WriteRow("pear", 2, "extra"))
+	noError(t, s.AddDefinedName(streamxlsx.DefinedName{Name: "Prices", DataRange: true}))
+	noError(t, s.AddDefinedName(streamxlsx.DefinedName{Name: "Region", DataRange: true, Local: true}))
+	noError(t, s.WriteSheet("prices"))
+	noError(t, s.AddDefinedName(streamxlsx.DefinedName{Name: "Region", Value: "$A$1", Local: true, Hidden: true}))
+	noError(t, s.WriteSheet("other"))
+	noError(t, s.Close())
+
+	workbook := readPart(t, buf.Bytes(), "xl/workbook.xml")
+	mustContain(t, workbook, `<definedName name="TaxRate" comment="VAT">0.21</definedName>`)
+	mustContain(t, workbook, `<definedName name="Total">SUM(Prices)</definedName>`)
+	mustContain(t, workbook, `<definedName name="Prices">&#39;prices&#39;!$A$1:$C$2</definedName>`)
+	mustContain(t, workbook, `<definedName name="Region" localSheetId="0">&#39;prices&#39;!$A$1:$C$2</definedName>`)
+	mustContain(t, workbook, `<definedName name="Region" localSheetId="1" hidden="true">$A$1</definedName>`)
+
+	t.Run("errors", func(t *testing.T) {
+		for _, n := range []streamxlsx.DefinedName{
+			{Name: "A1", Value: "1"},
+			{Name: "1st", Value: "1"},
+			{Name: "_xlnm.Print_Area", Value: "1"},
+			{Name: "NoValue"},
+			{Name: "Both", Value: "1", DataRange: true},
+		} {
+			s := streamxlsx.New(&bytes.Buffer{})
+			if err := s.AddDefinedName(n); err == nil {
+				t.Fatalf("expected an error for %#v", n)
+			}
+		}
+
+		s := streamxlsx.New(&bytes.Buffer{})
+		noError(t, s.AddDefinedName(streamxlsx.DefinedName{Name: "Rate", Value: "1"}))
+		if err := s.AddDefinedName(streamxlsx.DefinedName{Name: "RATE", Value: "2"}); err == nil {
+			t.Fatal("expected an error")
+		}
+
+		s = streamxlsx.New(&bytes.Buffer{})
+		noError(t, s.AddDefinedName(streamxlsx.DefinedName{Name: "Sales", Value: "1"}))
+		if err := s.StartTable(streamxlsx.Table{Name: "Sales", Columns: []streamxlsx.TableColumn{{Name: "a"}}}); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestSheetView(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.SetSheetView(streamxlsx.SheetView{
+		Zoom:          150,
+		HideGridlines: true,
+		HideHeadings:  true,
+		RightToLeft:   true,
+		ShowFormulas:  true,
+		Selected:      "b2",
+		TabColor:      "ff0000",
+	}))
+	noError(t, s.SetOutline(streamxlsx.Outline{SummaryAbove: true}))
+	noError(t, s.WriteRow("hello"))
+	noError(t, s.WriteSheet("view"))
+	noError(t, s.SetSheetView(streamxlsx.SheetView{Zoom: 80}))
+	noError(t, s.WriteSheet("zoom"))
+	noError(t, s.Close())
+
+	sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	mustContain(t, sheet, `<sheetPr><tabColor rgb="FFFF0000"/><outlinePr summaryBelow="0"/></sheetPr><sheetViews><sheetView showFormulas="1" showGridLines="0" showRowColHeaders="0" rightToLeft="1" zoomScale="150" zoomScaleNormal="150" workbookViewId="0"><selection activeCell="B2" sqref="B2"/></sheetView></sheetViews><sheetData>`)
+
+	sheet = readPart(t, buf.Bytes(), "xl/worksheets/sheet2.xml")
+	mustContain(t, sheet, `><sheetViews><sheetView zoomScale="80" zoomScaleNormal="80" workbookViewId="0"/></sheetViews><sheetData>`)
+
+	t.Run("errors", func(t *testing.T) {
+		for _, v := range []streamxlsx.SheetView{
+			{Zoom: 5},
+			{Selected: "A0"},
+			{TabColor: "red"},
+			{TabColor: "#ff00000"},
+		} {
+			s := streamxlsx.New(&bytes.Buffer{})
+			if err := s.SetSheetView(v); err == nil {
+				t.Fatalf("expected an error for %#v", v)
+			}
+		}
+
+		s := streamxlsx.New(&bytes.Buffer{})
+		noError(t, s.WriteRow("hello"))
+		mustBeError(t, streamxlsx.ErrSheetStarted, s.SetSheetView(streamxlsx.SheetView{}))
+	})
+}
+
+func TestSheetState(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.SetSheetState(streamxlsx.SheetHidden))
+	noError(t, s.WriteRow("lookup"))
+	noError(t, s.WriteSheet("lookup"))
+	noError(t, s.SetSheetState(streamxlsx.SheetVeryHidden))
+	noError(t, s.WriteSheet("secret"))
+	noError(t, s.WriteRow("data"))
+	noError(t, s.WriteSheet("data"))
+	noError(t, s.WriteSheet("summary"))
+	s.ActiveSheet = "Summary" // titles are case insensitive
+	s.FirstVisibleTab = "DATA"
+	noError(t, s.Close())
+
+	workbook := readPart(t, buf.Bytes(), "xl/workbook.xml")
+	mustContain(t, workbook, `<bookViews><workbookView firstSheet="2" activeTab="3"></workbookView></bookViews>`)
+	mustContain(t, workbook, `<sheet name="lookup" sheetId="1" state="hidden" r:id="sheetId1"></sheet>`)
+	mustContain(t, workbook, `<sheet name="secret" sheetId="2" state="veryHidden" r:id="sheetId2"></sheet>`)
+	mustContain(t, workbook, `<sheet name="data" sheetId="3" r:id="sheetId3"></sheet>`)
+
+	t.Run("default active", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		noError(t, s.SetSheetState(streamxlsx.SheetHidden))
+		noError(t, s.WriteSheet("hidden"))
+		noError(t, s.WriteSheet("visible"))
+		noError(t, s.Close())
+		workbook := readPart(t, buf.Bytes(), "xl/workbook.xml")
+		mustContain(t, workbook, `<bookViews><workbookView activeTab="1"></workbookView></bookViews>`)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		s := streamxlsx.New(&bytes.Buffer{})
+		if err := s.SetSheetState("gone"); err == nil {
+			t.Fatal("expected an error")
+		}
+
+		s = streamxlsx.New(&bytes.Buffer{})
+		noError(t, s.SetSheetState(streamxlsx.SheetHidden))
+		mustBeError(t, streamxlsx.ErrNoVisibleSheet, s.Close())
+
+		s = streamxlsx.New(&bytes.Buffer{})
+		noError(t, s.SetSheetState(streamxlsx.SheetHidden))
+		noError(t, s.WriteSheet("hidden"))
+		noError(t, s.WriteSheet("visible"))
+		s.ActiveSheet = "hidden"
+		if err := s.Close(); err == nil {
+			t.Fatal("expected an error")
+		}
+
+		s = streamxlsx.New(&bytes.Buffer{})
+		s.ActiveSheet = "nosuch"
+		if err := s.Close(); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestSheetOrder(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.WriteRow("north"))
+	noError(t, s.WriteSheet("north"))
+	noError(t, s.SetPageSetup(streamxlsx.PageSetup{PrintArea: "A1:B2"}))
+	noError(t, s.WriteRow("south"))
+	noError(t, s.WriteSheet("south"))
+	noError(t, s.WriteRow("summary"))
+	noError(t, s.WriteSheet("summary"))
+	s.SheetOrder = []string{"Summary", "south"}
+	s.ActiveSheet = "south"
+	noError(t, s.Close())
+
+	workbook := readPart(t, buf.Bytes(), "xl/workbook.xml")
+	mustContain(t, workbook, `<bookViews><workbookView activeTab="1"></workbookView></bookViews><sheets><sheet name="summary" sheetId="3" r:id="sheetId3"></sheet><sheet name="south" sheetId="2" r:id="sheetId2"></sheet><sheet name="north" sheetId="1" r:id="sheetId1"></sheet></sheets>`)
+	mustContain(t, workbook, `<definedName name="_xlnm.Print_Area" localSheetId="1">`)
+
+	f, err := streamxlsx.TestParse(buf.Bytes())
+	noError(t, err)
+	mustEq(t, "summary", f.Sheets[0].Name)
+	mustEq(t, "summary", f.Sheets[0].Cells[0].Value)
+	mustEq(t, "north", f.Sheets[2].Cells[0].Value)
+
+	t.Run("errors", func(t *testing.T) {
+		s := streamxlsx.New(&bytes.Buffer{})
+		noError(t, s.WriteSheet("one"))
+		s.SheetOrder = []string{"two"}
+		if err := s.Close(); err == nil {
+			t.Fatal("expected an error")
+		}
+
+		s = streamxlsx.New(&bytes.Buffer{})
+		noError(t, s.WriteSheet("one"))
+		s.SheetOrder = []string{"one", "one"}
+		if err := s.Close(); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestProtection(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.WriteRow("name", "amount"))
+	noError(t, s.WriteRow("fixed", s.CellProtection(false, false, 12)))
+	noError(t, s.WriteRow("formula", s.CellProtection(true, true, s.Format("0.00", 3.5))))
+	noError(t, s.ProtectSheet(streamxlsx.SheetProtection{
+		Password:    "secret",
+		FormatCells: true,
+		Sort:        true,
+	}))
+	noError(t, s.WriteSheet("template"))
+	noError(t, s.ProtectSheet(streamxlsx.SheetProtection{}))
+	noError(t, s.WriteSheet("no password"))
+	noError(t, s.ProtectWorkbook(streamxlsx.WorkbookProtection{Password: "secret", Structure: true}))
+	noError(t, s.Close())
+
+	sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	mustContain(t, sheet, `<c r="B2" t="n" s="1"><v>12</v></c>`)
+	mustContain(t, sheet, `<c r="B3" t="n" s="3"><v>3.500000</v></c>`)
+	mustContain(t, sheet, `</sheetData><sheetProtection algorithmName="SHA-512" hashValue="`)
+	mustContain(t, sheet, `spinCount="100000" sheet="1" objects="1" scenarios="1" formatCells="0" formatColumns="1" formatRows="1" insertColumns="1" insertRows="1" insertHyperlinks="1" deleteColumns="1" deleteRows="1" selectLockedCells="0" sort="0" autoFilter="1" pivotTables="1" selectUnlockedCells="0"/></worksheet>`)
+
+	sheet = readPart(t, buf.Bytes(), "xl/worksheets/sheet2.xml")
+	mustContain(t, sheet, `</sheetData><sheetProtection sheet="1" objects="1"`)
+
+	styles := readPart(t, buf.Bytes(), "xl/styles.xml")
+	mustContain(t, styles, `<xf numFmtId="0" fontId="0" fillId="0" borderId="0" applyProtection="1" xfId="0"><protection locked="0"></protection></xf>`)
+	mustContain(t, styles, `<xf numFmtId="2" fontId="0" fillId="0" borderId="0" applyNumberFormat="1" applyProtection="1" xfId="0"><protection locked="1" hidden="1"></protection></xf>`)
+
+	workbook := readPart(t, buf.Bytes(), "xl/workbook.xml")
+	mustContain(t, workbook, `<workbookProtection workbookAlgorithmName="SHA-512" workbookHashValue="`)
+	mustContain(t, workbook, `workbookSpinCount="100000" lockStructure="1"></workbookProtection><sheets>`)
+	if strings.Contains(workbook, "definedNames") {
+		t.Fatalf("unexpected definedNames: %s", workbook)
+	}
+}
+
+func TestProperties(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	s.Properties = &streamxlsx.Properties{
+		Title:       "Sales & returns",
+		Creator:     "Accounting",
+		Keywords:    "sales, 2020",
+		Created:     time.Date(2020, 3, 1, 12, 30, 0, 0, time.UTC),
+		Company:     "ACME",
+		Application: "exporter",
+	}
+	noError(t, s.WriteRow("hello"))
+	noError(t, s.Close())
+
+	core := readPart(t, buf.Bytes(), "docProps/core.xml")
+	mustContain(t, core, `<dc:title>Sales &amp; returns</dc:title><dc:creator>Accounting</dc:creator><cp:keywords>sales, 2020</cp:keywords><dcterms:created xsi:type="dcterms:W3CDTF">2020-03-01T12:30:00Z</dcterms:created><dcterms:modified xsi:type="dcterms:W3CDTF">2020-03-01T12:30:00Z</dcterms:modified></cp:coreProperties>`)
+	app := readPart(t, buf.Bytes(), "docProps/app.xml")
+	mustContain(t, app, `<Application>exporter</Application><Company>ACME</Company></Properties>`)
+
+	rels := readPart(t, buf.Bytes(), "_rels/.rels")
+	mustContain(t, rels, `<Relationship Id="rId2" Target="/docProps/core.xml" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties"></Relationship>`)
+	mustContain(t, rels, `<Relationship Id="rId3" Target="/docProps/app.xml" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/extended-properties"></Relationship>`)
+	types := readPart(t, buf.Bytes(), "[Content_Types].xml")
+	mustContain(t, types, `<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>`)
+	mustContain(t, types, `<Override PartName="/docProps/app.xml" ContentType="application/vnd.openxmlformats-officedocument.extended-properties+xml"/>`)
+
+	t.Run("defaults", func(t *testing.T) {
+		props := &streamxlsx.Properties{Title: "reused"}
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		s.Properties = props
+		noError(t, s.Close())
+		core := readPart(t, buf.Bytes(), "docProps/core.xml")
+		mustContain(t, core, `<dcterms:created xsi:type="dcterms:W3CDTF">`)
+		if !props.Created.IsZero() || !props.Modified.IsZero() {
+			t.Fatalf("properties changed: %#v", props)
+		}
+	})
+
+	t.Run("none", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		noError(t, s.Close())
+		rels := readPart(t, buf.Bytes(), "_rels/.rels")
+		if strings.Contains(rels, "docProps") {
+			t.Fatalf("unexpected docProps: %s", rels)
+		}
+	})
+}
+
+func TestCustomProperties(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := streamxlsx.New(buf)
+	noError(t, s.SetCustomProperty("Classification", "internal"))
+	noError(t, s.SetCustomProperty("Tenant", 42))
+	noError(t, s.SetCustomProperty("Ratio", 0.5))
+	noError(t, s.SetCustomProperty("Reviewed", true))
+	noError(t, s.SetCustomProperty("Generated", time.Date(2020, 3, 1, 12, 30, 0, 0, time.UTC)))
+	noError(t, s.SetCustomProperty("classification", "confidential"))
+	noError(t, s.Close())
+
+	custom := readPart(t, buf.Bytes(), "docProps/custom.xml")
+	mustContain(t, custom, `<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="2" name="classification"><vt:lpwstr>confidential</vt:lpwstr></property>`)
+	mustContain(t, custom, `<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="3" name="Tenant"><vt:i4>42</vt:i4></property>`)
+	mustContain(t, custom, `pid="4" name="Ratio"><vt:r8>0.5</vt:r8>`)
+	mustContain(t, custom, `pid="5" name="Reviewed"><vt:bool>true</vt:bool>`)
+	mustContain(t, custom, `pid="6" name="Generated"><vt:filetime>2020-03-01T12:30:00Z</vt:filetime>`)
+
+	rels := readPart(t, buf.Bytes(), "_rels/.rels")
+	mustContain(t, rels, `<Relationship Id="rId4" Target="/docProps/custom.xml" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/custom-properties"></Relationship>`)
+	types := readPart(t, buf.Bytes(), "[Content_Types].xml")
+	mustContain(t, types, `<Override PartName="/docProps/custom.xml" ContentType="application/vnd.openxmlformats-officedocument.custom-properties+xml"/>`)
+
+	t.Run("errors", func(t *testing.T) {
+		s := streamxlsx.New(&bytes.Buffer{})
+		if err := s.SetCustomProperty("", "x"); err == nil {
+			t.Fatal("expected an error")
+		}
+
+		s = streamxlsx.New(&bytes.Buffer{})
+		if err := s.SetCustomProperty("Data", []byte("x")); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestLimits(t *testing.T) {
+	t.Run("columns", func(t *testing.T) {
+		s := streamxlsx.New(&bytes.Buffer{})
+		noError(t, s.WriteRow(make([]interface{}, streamxlsx.MaxColumns)...))
+		err := s.WriteRow(make([]interface{}, streamxlsx.MaxColumns+1)...)
+		mustBeError(t, streamxlsx.ErrTooManyColumns, err)
+		mustBeError(t, streamxlsx.ErrTooManyColumns, s.Close())
+	})
+
+	// a million rows is slow
+	defer streamxlsx.SetMaxRows(10)()
+
+	t.Run("rows", func(t *testing.T) {
+		s := streamxlsx.New(&bytes.Buffer{})
+		for i := 0; i < 10; i++ {
+			noError(t, s.WriteRow())
+		}
+		mustBeError(t, streamxlsx.ErrTooManyRows, s.WriteRow("one too many"))
+	})
+
+	t.Run("rollover", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		s.Rollover = true
+		s.RolloverHeader = []interface{}{"header"}
+		for i := 0; i < 10; i++ {
+			noError(t, s.WriteRow())
+		}
+		noError(t, s.WriteRow("row 1"))
+		noError(t, s.WriteRow("row 2"))
+		noError(t, s.WriteSheet("data"))
+		noError(t, s.WriteRow("next"))
+		noError(t, s.Close())
+
+		xf, err := streamxlsx.TestParse(buf.Bytes())
+		noError(t, err)
+		if have, want := len(xf.Sheets), 3; have != want {
+			t.Fatalf("have %d, want %d", have, want)
+		}
+		mustEq(t, "data", xf.Sheets[0].Name)
+		mustEq(t, "data (2)", xf.Sheets[1].Name)
+		mustDeepEq(t,
+			[]streamxlsx.TestCell{
+				{"A1", "inlineStr", "header", 0},
+				{"A2", "inlineStr", "row 1", 0},
+				{"A3", "inlineStr", "row 2", 0},
+			},
+			xf.Sheets[1].Cells,
+		)
+		mustEq(t, "sheet 3", xf.Sheets[2].Name)
+	})
+
+	t.Run("rollover in a row group", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		s.Rollover = true
+		s.RolloverHeader = []interface{}{"header"}
+		noError(t, s.SetOutline(streamxlsx.Outline{RowLevels: 1}))
+		noError(t, s.BeginRowGroup(true))
+		for i := 0; i < 11; i++ {
+			noError(t, s.WriteRow("detail"))
+		}
+		noError(t, s.EndRowGroup())
+		noError(t, s.WriteSheet("data"))
+		noError(t, s.Close())
+
+		sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet2.xml")
+		mustContain(t, sheet, `<row r="1"><c r="A1" t="inlineStr"><is><t>header</t></is></c></row><row r="2" hidden="1" outlineLevel="1">`)
+	})
+
+	t.Run("rollover long title", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		s.Rollover = true
+		for i := 0; i < 11; i++ {
+			noError(t, s.WriteRow())
+		}
+		title := strings.Repeat("t", 30)
+		noError(t, s.WriteSheet(title))
+		noError(t, s.Close())
+
+		xf, err := streamxlsx.TestParse(buf.Bytes())
+		noError(t, err)
+		mustEq(t, title, xf.Sheets[0].Name)
+		mustEq(t, strings.Repeat("t", 27)+" (2)", xf.Sheets[1].Name)
+	})
+}
+
+func TestSheetNames(t *testing.T) {
+	t.Run("invalid", func(t *testing.T) {
+		for _, title := range []string{
+			"",
+			"this title is much too long for excel",
+			"a/b",
+			"[x]",
+			"'quoted'",
+			"history",
+			"Dup",
+		} {
+			s := streamxlsx.New(&bytes.Buffer{})
+			noError(t, s.WriteSheet("dup"))
+			var nameErr *streamxlsx.SheetNameError
+			if err := s.WriteSheet(title); !errors.As(err, &nameErr) {
+				t.Fatalf("title %q: have %v, want a SheetNameError", title, err)
+			}
+		}
+	})
+
+	t.Run("default title", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		noError(t, s.WriteSheet("Sheet 2"))
+		noError(t, s.WriteRow("unclosed"))
+		noError(t, s.Close())
+
+		xf, err := streamxlsx.TestParse(buf.Bytes())
+		noError(t, err)
+		mustEq(t, "sheet 2 (2)", xf.Sheets[1].Name)
+	})
+
+	t.Run("sanitize", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		s.SanitizeSheetNames = true
+		for _, title := range []string{
+			"",
+			"this title is much too long for excel",
+			"this title is much too long for excel",
+			"a/b",
+			"'quoted'",
+			"History",
+			"dup",
+			"Dup",
+		} {
+			noError(t, s.WriteSheet(title))
+		}
+		noError(t, s.Close())
+
+		xf, err := streamxlsx.TestParse(buf.Bytes())
+		noError(t, err)
+		var names []string
+		for _, sh := range xf.Sheets {
+			names = append(names, sh.Name)
+		}
+		mustDeepEq(t,
+			[]string{
+				"sheet",
+				"this title is much too long for",
+				"this title is much too long (2)",
+				"a_b",
+				"quoted",
+				"History (2)",
+				"dup",
+				"Dup (2)",
+			},
+			names,
+		)
+	})
+}
+
+func TestLongStrings(t *testing.T) {
+	long := strings.Repeat("a", streamxlsx.MaxCellLength+10)
+
+	t.Run("error", func(t *testing.T) {
+		s := streamxlsx.New(&bytes.Buffer{})
+		err := s.WriteRow("short", long)
+		var lenErr *streamxlsx.CellLengthError
+		if !errors.As(err, &lenErr) {
+			t.Fatalf("have %v, want a CellLengthError", err)
+		}
+		mustEq(t, "B1", lenErr.Ref)
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		s.LongStrings = streamxlsx.LongStringTruncate
+		noError(t, s.WriteRow(long, "next"))
+		noError(t, s.Close())
+
+		xf, err := streamxlsx.TestParse(buf.Bytes())
+		noError(t, err)
+		cells := xf.Sheets[0].Cells
+		mustEq(t, strings.Repeat("a", streamxlsx.MaxCellLength-3)+"...", cells[0].Value)
+		mustEq(t, "B1", cells[1].Ref)
+	})
+
+	t.Run("split", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		s := streamxlsx.New(buf)
+		s.LongStrings = streamxlsx.LongStringSplit
+		noError(t, s.WriteRow(long, "next"))
+		noError(t, s.Close())
+
+		xf, err := streamxlsx.TestParse(buf.Bytes())
+		noError(t, err)
+		cells := xf.Sheets[0].Cells
+		mustDeepEq(t,
+			[]streamxlsx.TestCell{
+				{"A1", "inlineStr", strings.Repeat("a", streamxlsx.MaxCellLength), 0},
+				{"B1", "inlineStr", "aaaaaaaaaa", 0},
+				{"C1", "inlineStr", "next", 0},
+			},
+			cells,
+		)
+	})
+
+	t.Run("utf16", func(t *testing.T) {
+		// emoji are two UTF-16 code units
+		emoji := strings.Repeat("😀", 20_000)
+
+		s := streamxlsx.New(&bytes.Buffer{})
+		var lenErr *streamxlsx.CellLengthError
+		if err := s.WriteRow(emoji); !errors.As(err, &lenErr) {
+			t.Fatalf("have %v, want a CellLengthError", err)
+		}
+		if have, want := lenErr.Length, 40_000; have != want {
+			t.Fatalf("have %d, want %d", have, want)
+		}
+
+		buf := &bytes.Buffer{}
+		s = streamxlsx.New(buf)
+		s.LongStrings = streamxlsx.LongStringSplit
+		noError(t, s.WriteRow("a"+emoji))
+		noError(t, s.Close())
+
+		xf, err := streamxlsx.TestParse(buf.Bytes())
+		noError(t, err)
+		cells := xf.Sheets[0].Cells
+		// 1 + 16_383*2 units, the next emoji doesn't fit
+		mustEq(t, "a"+strings.Repeat("😀", 16_383), cells[0].Value)
+		mustEq(t, strings.Repeat("😀", 20_000-16_383), cells[1].Value)
+	})
+}
+
 func noError(t *testing.T, err error) {
 	t.Helper()
 	if err != nil {
@@ -283,6 +1558,39 @@ func mustEq(t *testing.T, want, have string) {
 	}
 }
 
+func mustContain(t *testing.T, s, sub string) {
+	t.Helper()
+	if !strings.Contains(s, sub) {
+		t.Fatalf("%q not found in %q", sub, s)
+	}
+}
+
+// readPart gives a single file from the .xlsx zip.
+func readPart(t *testing.T, b []byte, name string) string {
+	t.Helper()
+	z, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	noError(t, err)
+	for _, f := range z.File {
+		if f.Name == name {
+			fh, err := f.Open()
+			noError(t, err)
+			defer fh.Close()
+			v, err := ioutil.ReadAll(fh)
+			noError(t, err)
+			return string(v)
+		}
+	}
+	t.Fatalf("no such file: %s", name)
+	return ""
+}
+
+func mustBeError(t *testing.T, want, have error) {
+	t.Helper()
+	if !errors.Is(have, want) {
+		t.Fatalf("have %v, want %v", have, want)
+	}
+}
+
 func mustDeepEq(t *testing.T, want, have interface{}) {
 	t.Helper()
 	if !reflect.DeepEqual(have, want) {
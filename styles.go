@@ -2,7 +2,9 @@ package streamxlsx
 
 import (
 	"encoding/xml"
+	"fmt"
 	"io"
+	"strings"
 )
 
 const DefaultDatetimeFormat = "m/d/yy h:mm"
@@ -15,6 +17,7 @@ type Stylesheet struct {
 	NumFmts      []NumFmt
 	CellXfs      []Xf
 	CellStyleXfs []Xf
+	Dxfs         []Dxf
 }
 
 type stylesheetXML struct {
@@ -26,6 +29,7 @@ type stylesheetXML struct {
 	Borders      bordersXML `xml:"borders"`
 	CellStyleXfs xfsXML     `xml:"cellStyleXfs"`
 	CellXfs      xfsXML     `xml:"cellXfs"`
+	Dxfs         dxfsXML    `xml:"dxfs"`
 }
 
 type numFmtsXML struct {
@@ -100,7 +104,82 @@ type Xf struct {
 	FillID            int  `xml:"fillId,attr"`
 	BorderID          int  `xml:"borderId,attr"`
 	ApplyNumberFormat int  `xml:"applyNumberFormat,attr,omitempty"`
+	ApplyProtection   int  `xml:"applyProtection,attr,omitempty"`
 	XfID              *int `xml:"xfId,attr,omitempty"`
+	// Protection only has effect in a protected sheet.
+	Protection *Protection `xml:"protection"`
+}
+
+// Protection of cells. Cells are locked by default.
+type Protection struct {
+	Locked int `xml:"locked,attr"`
+	Hidden int `xml:"hidden,attr,omitempty"` // hides the formula
+}
+
+// sameXf compares the values of the xfs.
+func sameXf(a, b Xf) bool {
+	if (a.XfID == nil) != (b.XfID == nil) || (a.XfID != nil && *a.XfID != *b.XfID) {
+		return false
+	}
+	if (a.Protection == nil) != (b.Protection == nil) || (a.Protection != nil && *a.Protection != *b.Protection) {
+		return false
+	}
+	a.XfID, b.XfID = nil, nil
+	a.Protection, b.Protection = nil, nil
+	return a == b
+}
+
+// Dxf is a differential format, which is applied on top of the cell's format.
+// Used by conditional formatting. Colors are "RRGGBB".
+type Dxf struct {
+	FontColor    string
+	Bold, Italic bool
+	FillColor    string
+	NumFmt       string // a number format code, such as "0.00"
+}
+
+type dxfsXML struct {
+	Count int      `xml:"count,attr"`
+	Dxfs  []dxfXML `xml:"dxf"`
+}
+
+type dxfXML struct {
+	Font   *dxfFontXML `xml:"font"`
+	NumFmt *NumFmt     `xml:"numFmt"`
+	Fill   *dxfFillXML `xml:"fill"`
+}
+
+type dxfFontXML struct {
+	Bold   *struct{} `xml:"b"`
+	Italic *struct{} `xml:"i"`
+	Color  *colorXML `xml:"color"`
+}
+
+type dxfFillXML struct {
+	BgColor colorXML `xml:"patternFill>bgColor"`
+}
+
+type colorXML struct {
+	RGB string `xml:"rgb,attr"`
+}
+
+// argb makes an "FFRRGGBB" color from "RRGGBB".
+func argb(rgb string) string {
+	return "FF" + strings.ToUpper(strings.TrimPrefix(rgb, "#"))
+}
+
+// validateColor checks for an "RRGGBB" color, with an optional "#".
+func validateColor(rgb string) error {
+	c := strings.TrimPrefix(rgb, "#")
+	if len(c) != 6 {
+		return fmt.Errorf("invalid color: %q", rgb)
+	}
+	for _, r := range c {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return fmt.Errorf("invalid color: %q", rgb)
+		}
+	}
+	return nil
 }
 
 type NumFmt struct {
@@ -147,7 +226,7 @@ func (s *Stylesheet) GetNumFmtID(code string) int {
 // The ID is the entry in the array, 0-based
 func (s *Stylesheet) GetCellID(xf Xf) int {
 	for i, x := range s.CellXfs {
-		if x == xf {
+		if sameXf(x, xf) {
 			return i
 		}
 	}
@@ -159,7 +238,7 @@ func (s *Stylesheet) GetCellID(xf Xf) int {
 // The ID is the entry in the array, 0-based
 func (s *Stylesheet) GetCellStyleID(xf Xf) int {
 	for i, x := range s.CellStyleXfs {
-		if x == xf {
+		if sameXf(x, xf) {
 			return i
 		}
 	}
@@ -167,10 +246,55 @@ func (s *Stylesheet) GetCellStyleID(xf Xf) int {
 	return len(s.CellStyleXfs) - 1
 }
 
+// makes a Dxf ID
+// The ID is the entry in the array, 0-based
+func (s *Stylesheet) GetDxfID(dxf Dxf) int {
+	for i, d := range s.Dxfs {
+		if d == dxf {
+			return i
+		}
+	}
+	s.Dxfs = append(s.Dxfs, dxf)
+	return len(s.Dxfs) - 1
+}
+
+func (s *Stylesheet) dxfsXML() dxfsXML {
+	x := dxfsXML{
+		Count: len(s.Dxfs),
+	}
+	for _, d := range s.Dxfs {
+		var dx dxfXML
+		if d.FontColor != "" || d.Bold || d.Italic {
+			dx.Font = &dxfFontXML{}
+			if d.Bold {
+				dx.Font.Bold = &struct{}{}
+			}
+			if d.Italic {
+				dx.Font.Italic = &struct{}{}
+			}
+			if d.FontColor != "" {
+				dx.Font.Color = &colorXML{argb(d.FontColor)}
+			}
+		}
+		if d.NumFmt != "" {
+			dx.NumFmt = &NumFmt{
+				ID:   s.GetNumFmtID(d.NumFmt),
+				Code: d.NumFmt,
+			}
+		}
+		if d.FillColor != "" {
+			dx.Fill = &dxfFillXML{BgColor: colorXML{argb(d.FillColor)}}
+		}
+		x.Dxfs = append(x.Dxfs, dx)
+	}
+	return x
+}
+
 func writeStylesheet(fh io.Writer, s *Stylesheet) error {
 	fh.Write([]byte(xml.Header))
 	enc := xml.NewEncoder(fh)
 
+	dxfs := s.dxfsXML() // this can add NumFmts
 	return enc.Encode(stylesheetXML{
 		XMLNS: "http://schemas.openxmlformats.org/spreadsheetml/2006/main",
 		NumFmts: numFmtsXML{
@@ -209,5 +333,6 @@ func writeStylesheet(fh io.Writer, s *Stylesheet) error {
 			Count: len(s.CellXfs),
 			Xfs:   s.CellXfs,
 		},
+		Dxfs: dxfs,
 	})
 }
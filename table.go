@@ -0,0 +1,287 @@
+package streamxlsx
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Table makes the rows written after StartTable() an Excel table (a
+// "ListObject"), with a header row, filter buttons, and optionally a totals
+// row.
+type Table struct {
+	// Name is used in formulas. Defaults to "TableN".
+	Name string
+	// The header names. These must be unique.
+	Columns []TableColumn
+	// Column is the first (0-based) column of the table. WriteRow() starts
+	// its rows in this column while the table is open.
+	Column int
+	// Style is a built in table style, such as "TableStyleMedium9". Defaults
+	// to "TableStyleMedium2".
+	Style          string
+	BandedRows     bool
+	BandedColumns  bool
+	NoFilterButton bool
+	// TotalsRow adds a row with the TotalsLabel or TotalsFunction of the
+	// columns.
+	TotalsRow bool
+}
+
+// TableColumn is a column in a Table.
+type TableColumn struct {
+	Name string
+	// for the totals row. Only one of these should be set.
+	TotalsLabel    string
+	TotalsFunction string // "sum", "average", "count", "countNums", "max", "min", "stdDev", or "var"
+}
+
+type table struct {
+	Table
+	id                int // in the workbook
+	relID             string
+	firstRow, lastRow int // 0-based, the header and the last row
+}
+
+// function number for SUBTOTAL(), excluding hidden rows
+var totalsFunctions = map[string]int{
+	"average":   101,
+	"countNums": 102,
+	"count":     103,
+	"max":       104,
+	"min":       105,
+	"stdDev":    107,
+	"sum":       109,
+	"var":       110,
+}
+
+// StartTable writes the header row of a table. All rows written until
+// EndTable() or WriteSheet() are in the table.
+func (s *StreamXLSX) StartTable(t Table) error {
+	if s.error != nil {
+		return s.error
+	}
+	sh, err := s.sheet()
+	if err != nil {
+		s.error = err
+		return err
+	}
+	if err := s.startTable(sh, t); err != nil {
+		s.error = err
+		return err
+	}
+	return nil
+}
+
+func (s *StreamXLSX) startTable(sh *sheetEncoder, t Table) error {
+	if sh.openTable != nil {
+		return errors.New("there is already an open table")
+	}
+	if t.Name == "" {
+		t.Name = fmt.Sprintf("Table%d", s.tableCount+1)
+	}
+	if err := validateTableName(t.Name); err != nil {
+		return err
+	}
+	if s.nameTaken(t.Name, false) {
+		return fmt.Errorf("duplicate table name: %q", t.Name)
+	}
+	if err := validateTableColumns(t.Columns); err != nil {
+		return err
+	}
+	if t.Style == "" {
+		t.Style = "TableStyleMedium2"
+	}
+
+	header := make([]interface{}, len(t.Columns))
+	for i, c := range t.Columns {
+		header[i] = c.Name
+	}
+	row := sh.rows
+	if err := sh.writeRowAt(row, t.Column, RowOptions{}, header...); err != nil {
+		return err
+	}
+
+	s.tableCount++
+	s.tableNames = append(s.tableNames, t.Name)
+	sh.openTable = &table{
+		Table:    t,
+		id:       s.tableCount,
+		firstRow: row,
+	}
+	return nil
+}
+
+// EndTable closes the table started with StartTable(), and writes its totals
+// row, if any.
+func (s *StreamXLSX) EndTable() error {
+	if s.error != nil {
+		return s.error
+	}
+	sh, err := s.sheet()
+	if err != nil {
+		s.error = err
+		return err
+	}
+	if sh.openTable == nil {
+		s.error = errors.New("no open table")
+		return s.error
+	}
+	if err := sh.endTable(); err != nil {
+		s.error = err
+		return err
+	}
+	return nil
+}
+
+func (sh *sheetEncoder) endTable() error {
+	t := sh.openTable
+	sh.openTable = nil
+
+	// a table needs at least a single data row, which can be empty
+	t.lastRow = sh.rows - 1
+	if t.lastRow == t.firstRow {
+		t.lastRow++
+		// the (empty) row is part of the table, so rows go after it
+		sh.rows = t.lastRow + 1
+	}
+	if t.TotalsRow {
+		t.lastRow++
+		totals := make([]interface{}, len(t.Columns))
+		for i, c := range t.Columns {
+			switch {
+			case c.TotalsFunction != "":
+				totals[i] = Cell{
+					formula: fmt.Sprintf("SUBTOTAL(%d,%s[%s])", totalsFunctions[c.TotalsFunction], t.Name, escapeTableColumn(c.Name)),
+				}
+			case c.TotalsLabel != "":
+				totals[i] = c.TotalsLabel
+			}
+		}
+		if err := sh.writeRowAt(t.lastRow, t.Column, RowOptions{}, totals...); err != nil {
+			return err
+		}
+	}
+
+	t.relID = fmt.Sprintf("tableId%d", len(sh.tables)+1)
+	sh.relations = append(sh.relations, relationship{
+		ID:     t.relID,
+		Target: fmt.Sprintf("/xl/tables/table%d.xml", t.id),
+		Type:   "http://schemas.openxmlformats.org/officeDocument/2006/relationships/table",
+	})
+	sh.tables = append(sh.tables, *t)
+	return nil
+}
+
+// firstColumn is where WriteRow() starts a row: at the open table, if any.
+func (sh *sheetEncoder) firstColumn() int {
+	if sh.openTable != nil {
+		return sh.openTable.Column
+	}
+	return 0
+}
+
+// Table names are like defined names, but without any dots.
+func validateTableName(name string) error {
+	if err := validateDefinedName(name); err != nil {
+		return err
+	}
+	if strings.Contains(name, ".") {
+		return fmt.Errorf("invalid table name: %q", name)
+	}
+	return nil
+}
+
+func validateTableColumns(cols []TableColumn) error {
+	if len(cols) == 0 {
+		return errors.New("table without columns")
+	}
+	var names []string
+	for _, c := range cols {
+		if strings.TrimSpace(c.Name) == "" {
+			return errors.New("table column without a name")
+		}
+		if sheetNameTaken(c.Name, names) {
+			return fmt.Errorf("duplicate table column: %q", c.Name)
+		}
+		names = append(names, c.Name)
+		if f := c.TotalsFunction; f != "" {
+			if _, ok := totalsFunctions[f]; !ok {
+				return fmt.Errorf("invalid totals function: %q", f)
+			}
+		}
+	}
+	return nil
+}
+
+// escapeTableColumn escapes the special characters for structured references.
+func escapeTableColumn(name string) string {
+	return strings.NewReplacer(
+		"'", "''",
+		"[", "'[",
+		"]", "']",
+		"#", "'#",
+	).Replace(name)
+}
+
+func encodeTableParts(w *bufio.Writer, tables []table) {
+	if len(tables) == 0 {
+		return
+	}
+	fmt.Fprintf(w, `<tableParts count="%d">`, len(tables))
+	for _, t := range tables {
+		fmt.Fprintf(w, `<tablePart r:id="%s"/>`, t.relID)
+	}
+	w.WriteString(`</tableParts>`)
+}
+
+func writeTable(fh io.Writer, t table) error {
+	w := bufio.NewWriter(fh)
+	w.WriteString(xml.Header)
+	ref := AsRef(t.Column, t.firstRow) + ":" + AsRef(t.Column+len(t.Columns)-1, t.lastRow)
+	fmt.Fprintf(w, `<table xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" id="%d"`, t.id)
+	writeAttr(w, "name", t.Name)
+	writeAttr(w, "displayName", t.Name)
+	writeAttr(w, "ref", ref)
+	if t.TotalsRow {
+		w.WriteString(` totalsRowCount="1"`)
+	} else {
+		w.WriteString(` totalsRowShown="0"`)
+	}
+	w.WriteString(`>`)
+	if !t.NoFilterButton {
+		filterLast := t.lastRow
+		if t.TotalsRow {
+			filterLast--
+		}
+		fmt.Fprintf(w, `<autoFilter ref="%s:%s"/>`, AsRef(t.Column, t.firstRow), AsRef(t.Column+len(t.Columns)-1, filterLast))
+	}
+	fmt.Fprintf(w, `<tableColumns count="%d">`, len(t.Columns))
+	for i, c := range t.Columns {
+		fmt.Fprintf(w, `<tableColumn id="%d"`, i+1)
+		writeAttr(w, "name", c.Name)
+		if c.TotalsLabel != "" {
+			writeAttr(w, "totalsRowLabel", c.TotalsLabel)
+		}
+		if c.TotalsFunction != "" {
+			writeAttr(w, "totalsRowFunction", c.TotalsFunction)
+		}
+		w.WriteString(`/>`)
+	}
+	w.WriteString(`</tableColumns>`)
+	w.WriteString(`<tableStyleInfo`)
+	writeAttr(w, "name", t.Style)
+	fmt.Fprintf(w, ` showFirstColumn="0" showLastColumn="0" showRowStripes="%d" showColumnStripes="%d"/>`, b2i(t.BandedRows), b2i(t.BandedColumns))
+	w.WriteString(`</table>`)
+	return w.Flush()
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
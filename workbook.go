@@ -4,37 +4,145 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"strings"
 )
 
 type workbookXML struct {
-	XMLName string     `xml:"workbook"`
-	XMLNS   string     `xml:"xmlns,attr"`
-	XMLNSR  string     `xml:"xmlns:r,attr"`
-	Sheets  []sheetXML `xml:"sheets>sheet"`
+	XMLName    string                 `xml:"workbook"`
+	XMLNS      string                 `xml:"xmlns,attr"`
+	XMLNSR     string                 `xml:"xmlns:r,attr"`
+	Protection *workbookProtectionXML `xml:"workbookProtection"`
+	BookViews  *bookViewsXML          `xml:"bookViews"`
+	Sheets     []sheetXML             `xml:"sheets>sheet"`
+	Names      *definedNamesXML       `xml:"definedNames"`
+}
+
+type workbookProtectionXML struct {
+	AlgorithmName string `xml:"workbookAlgorithmName,attr,omitempty"`
+	HashValue     string `xml:"workbookHashValue,attr,omitempty"`
+	SaltValue     string `xml:"workbookSaltValue,attr,omitempty"`
+	SpinCount     int    `xml:"workbookSpinCount,attr,omitempty"`
+	LockStructure int    `xml:"lockStructure,attr,omitempty"`
+	LockWindows   int    `xml:"lockWindows,attr,omitempty"`
+}
+
+// bookViews and definedNames can't be empty, so these are pointers
+type bookViewsXML struct {
+	Views []workbookViewXML `xml:"workbookView"`
+}
+
+type definedNamesXML struct {
+	Names []definedNameXML `xml:"definedName"`
+}
+
+type workbookViewXML struct {
+	FirstSheet int `xml:"firstSheet,attr,omitempty"`
+	ActiveTab  int `xml:"activeTab,attr,omitempty"`
 }
 
 type sheetXML struct {
-	Name string `xml:"name,attr"`
-	ID   string `xml:"sheetId,attr"`
-	RID  string `xml:"r:id,attr"`
+	Name  string `xml:"name,attr"`
+	ID    string `xml:"sheetId,attr"`
+	State string `xml:"state,attr,omitempty"`
+	RID   string `xml:"r:id,attr"`
+}
+
+// workbook has everything for workbook.xml.
+type workbook struct {
+	titles        []string
+	states        []string // "" is visible
+	names         []definedName
+	order         []int // sheet indexes in tab order
+	protection    *workbookProtectionXML
+	active, first int // 0-based sheet indexes
+}
+
+type definedNameXML struct {
+	Name         string `xml:"name,attr"`
+	Comment      string `xml:"comment,attr,omitempty"`
+	LocalSheetID *int   `xml:"localSheetId,attr,omitempty"`
+	Hidden       bool   `xml:"hidden,attr,omitempty"`
+	Value        string `xml:",chardata"`
 }
 
-func writeWorkbook(fh io.Writer, sheetTitles []string) error {
+// definedName is a name for a value or range, used in formulas or for
+// printing.
+type definedName struct {
+	name    string
+	sheet   int  // 0-based index of the sheet of local names and refs
+	local   bool // only valid on the sheet
+	value   string
+	refs    []string // on the sheet, joined with ","; instead of value
+	comment string
+	hidden  bool
+}
+
+// xml makes the XML version of the name. Tabs has the tab position of every
+// sheet.
+func (n definedName) xml(sheetTitles []string, tabs []int) definedNameXML {
+	d := definedNameXML{
+		Name:    n.name,
+		Comment: n.comment,
+		Hidden:  n.hidden,
+		Value:   n.value,
+	}
+	if n.local {
+		tab := tabs[n.sheet] // local sheet IDs are positions, not sheet IDs
+		d.LocalSheetID = &tab
+	}
+	if len(n.refs) > 0 {
+		var refs []string
+		for _, r := range n.refs {
+			refs = append(refs, SheetRef(sheetTitles[n.sheet], r))
+		}
+		d.Value = strings.Join(refs, ",")
+	}
+	return d
+}
+
+func writeWorkbook(fh io.Writer, wb workbook) error {
 	fh.Write([]byte(xml.Header))
 	enc := xml.NewEncoder(fh)
 
 	var sheets []sheetXML
-	for i, title := range sheetTitles {
+	tabs := make([]int, len(wb.titles))
+	for tab, i := range wb.order {
+		tabs[i] = tab
+		title := wb.titles[i]
+		state := wb.states[i]
+		if state == SheetVisible {
+			state = ""
+		}
 		sheets = append(sheets, sheetXML{
-			Name: title,
-			ID:   fmt.Sprintf("%d", i+1),
-			RID:  fmt.Sprintf("sheetId%d", i+1),
+			Name:  title,
+			ID:    fmt.Sprintf("%d", i+1),
+			State: state,
+			RID:   fmt.Sprintf("sheetId%d", i+1),
 		})
 	}
 
+	var views *bookViewsXML
+	if active, first := tabs[wb.active], tabs[wb.first]; active != 0 || first != 0 {
+		views = &bookViewsXML{[]workbookViewXML{{
+			FirstSheet: first,
+			ActiveTab:  active,
+		}}}
+	}
+
+	var names *definedNamesXML
+	for _, n := range wb.names {
+		if names == nil {
+			names = &definedNamesXML{}
+		}
+		names.Names = append(names.Names, n.xml(wb.titles, tabs))
+	}
+
 	return enc.Encode(workbookXML{
-		XMLNS:  "http://schemas.openxmlformats.org/spreadsheetml/2006/main",
-		XMLNSR: "http://schemas.openxmlformats.org/officeDocument/2006/relationships",
-		Sheets: sheets,
+		XMLNS:      "http://schemas.openxmlformats.org/spreadsheetml/2006/main",
+		XMLNSR:     "http://schemas.openxmlformats.org/officeDocument/2006/relationships",
+		Protection: wb.protection,
+		BookViews:  views,
+		Sheets:     sheets,
+		Names:      names,
 	})
 }